@@ -40,6 +40,48 @@ var (
 				{domainName: "FoundedYear", boltName: "fy", ft: stringType, leaf: false, flags: 0},
 			},
 			newConvStruct: func() any { return &genJsonCompany{} },
+			indexes: []genIndexMetadata{
+				{name: "ticker", unique: true, constraint: true, domainNames: []string{"Ticker"}, boltNames: []string{"ticker"}},
+			},
+			formats: map[string]string{
+				"Aliases": "gob",
+			},
+			stamps: []genStampMetadata{
+				{domainName: "Created", created: true},
+				{domainName: "Updated", created: false},
+			},
+		},
+		`Contact`: {
+			rootBucket: "contact",
+			buckets: []genKeyMetadata{
+				{domainName: "Record.Id", boltName: "id", ft: stringType, leaf: true, flags: 0},
+			},
+			newConvStruct: func() any { return &genJsonContact{} },
+			indexes: []genIndexMetadata{
+				{name: "home_city", unique: false, constraint: false, domainNames: []string{"Home.City"}, boltNames: []string{"home_city"}},
+			},
+			formats: map[string]string{
+				"Phone": "json",
+			},
+			encrypted: map[string]bool{
+				"Phone": true,
+			},
+			flattened: map[string]string{
+				"Record.Id":   "id",
+				"Home.Street": "home_street",
+				"Home.City":   "home_city",
+			},
+		},
+		`Document`: {
+			rootBucket: "document",
+			buckets: []genKeyMetadata{
+				{domainName: "Id", boltName: "id", ft: stringType, leaf: true, flags: 0},
+			},
+			newConvStruct: func() any { return &genJsonDocument{} },
+			renamed: map[string][]string{
+				"body": []string{"text"},
+			},
+			version: "Version",
 		},
 		`Events`: {
 			rootBucket: "events",
@@ -64,6 +106,13 @@ var (
 				{domainName: "Form", boltName: "form", ft: stringType, leaf: false, flags: 0},
 			},
 			newConvStruct: func() any { return &genJsonFiling{} },
+			indexes: []genIndexMetadata{
+				{name: "fy", unique: false, constraint: false, domainNames: []string{"FiscalYear"}, boltNames: []string{"fy"}},
+			},
+			defaults: map[string]any{
+				"Units": "usd",
+			},
+			notNull: []string{"Units"},
 		},
 		`UiSetting`: {
 			rootBucket: "settings",
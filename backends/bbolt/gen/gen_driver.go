@@ -4,11 +4,14 @@ package bboltgendriver
 // do not modify
 
 import (
+	"bytes"
 	"cmp"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hackborn/doc"
+	"github.com/hackborn/doc_drivers/driverkit"
 	"github.com/hackborn/onefunc/errors"
 	"github.com/hackborn/onefunc/reflect"
 	bolt "go.etcd.io/bbolt"
@@ -17,6 +20,8 @@ import (
 type genDriver struct {
 	db     *bolt.DB
 	format doc.Format
+	// keys seal the encrypted fields, and can be nil.
+	keys driverkit.KeyProvider
 }
 
 func (d *genDriver) Private(a any) error {
@@ -39,7 +44,7 @@ func (d *genDriver) Open(dataSourceName string) (doc.Driver, error) {
 	}
 
 	f := doc.FormatWithDefaults(genNewFormat())
-	return &genDriver{db: db, format: f}, nil
+	return &genDriver{db: db, format: f, keys: d.keys}, nil
 }
 
 func (d *genDriver) Close() error {
@@ -64,7 +69,7 @@ func (d *genDriver) Set(req doc.SetRequestAny, a doc.Allocator) (*doc.Optional,
 	err = d.db.Update(func(tx *bolt.Tx) error {
 		rootB, lastErr := tx.CreateBucketIfNotExists([]byte(data.p.rootBucket))
 		b := rootB
-		for _, node := range data.p.nodes {
+		for i, node := range data.p.nodes {
 			if lastErr != nil {
 				return lastErr
 			}
@@ -82,7 +87,8 @@ func (d *genDriver) Set(req doc.SetRequestAny, a doc.Allocator) (*doc.Optional,
 				if err != nil {
 					return err
 				}
-				return b.Put(genItob(id), data.value)
+				// Autoincs are always the leaf, so this is the key.
+				data.p.nodes[i].value = genItob(id)
 			}
 		}
 		if lastErr != nil {
@@ -95,14 +101,58 @@ func (d *genDriver) Set(req doc.SetRequestAny, a doc.Allocator) (*doc.Optional,
 		if err != nil {
 			return err
 		}
-		err = b.Put(key, data.value)
-		return err
+		if data.meta.version != "" {
+			data.value, err = data.meta.setVersion(data.value, b.Get(key), req.ItemAny())
+			if err != nil {
+				return err
+			}
+		}
+		if len(data.meta.stamps) > 0 {
+			var prev []byte
+			// New items always get a new created stamp.
+			if req.GetFilter().Rule != doc.RuleCreateItem {
+				prev = b.Get(key)
+			}
+			data.value, err = data.meta.stamp(data.value, prev, req.ItemAny(), time.Now().UTC())
+			if err != nil {
+				return err
+			}
+		}
+		if len(data.meta.indexes) > 0 {
+			err = d.setIndexes(tx, b, key, data, req.ItemAny())
+			if err != nil {
+				return err
+			}
+		}
+		value, err := data.meta.encodeStored(data.value)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, value)
 		//		return b.Put(key, data.value)
 	})
+	if err == nil && data.meta.version != "" {
+		// The response has the stored version, so it can be set again.
+		err = data.meta.nextVersion(a.New(), req.ItemAny())
+	}
 
 	return nil, err
 }
 
+// setIndexes updates the indexes for the item that
+// is about to be stored at key in b.
+func (d *genDriver) setIndexes(tx *bolt.Tx, b *bolt.Bucket, key boltKey, data setData, item any) error {
+	entry, err := encodeEntry(data.p)
+	if err != nil {
+		return err
+	}
+	prev, err := readPrev(data.meta, d.keys, b, key, item)
+	if err != nil {
+		return err
+	}
+	return putIndexes(tx, data.meta, entry, item, prev)
+}
+
 type setData struct {
 	meta  *genMetadata
 	p     *path
@@ -117,10 +167,14 @@ func (d *genDriver) prepareSet(req doc.SetRequestAny, a doc.Allocator) (setData,
 		return ps, fmt.Errorf("missing metadata for \"%v\"", tn)
 	}
 	ps.p = newPath(meta.rootBucket, meta.buckets)
-	reflect.Get(req.ItemAny(), ps.p)
+	genGetFields(req.ItemAny(), meta.DomainKeys(), ps.p)
+
+	if err := meta.checkNotNull(req.ItemAny()); err != nil {
+		return ps, err
+	}
 
 	// Marshal the data.
-	dbitem, err := meta.toDb(req.ItemAny())
+	dbitem, err := meta.toDb(req.ItemAny(), d.keys)
 	if err != nil {
 		return ps, err
 	}
@@ -139,7 +193,10 @@ func (d *genDriver) Get(req doc.GetRequest, a doc.Allocator) (*doc.Optional, err
 		return nil, err
 	}
 	err = d.db.View(func(tx *bolt.Tx) error {
-		it, err := newGetIterator(get.meta, tx, get.p, a)
+		if get.index != nil {
+			return d.getIndexed(tx, get, a)
+		}
+		it, err := newGetIterator(get.meta, d.keys, tx, get.p, a)
 		if err != nil {
 			return err
 		}
@@ -155,6 +212,10 @@ func (d *genDriver) Get(req doc.GetRequest, a doc.Allocator) (*doc.Optional, err
 type getData struct {
 	meta *genMetadata
 	p    *path
+	// index is set when the condition is answered from an
+	// index, in which case indexKey is the value bucket.
+	index    *genIndexMetadata
+	indexKey []byte
 }
 
 func (d *genDriver) prepareGet(req doc.GetRequest, a doc.Allocator) (getData, error) {
@@ -165,10 +226,41 @@ func (d *genDriver) prepareGet(req doc.GetRequest, a doc.Allocator) (getData, er
 		return get, fmt.Errorf("missing metadata for \"%v\"", tn)
 	}
 	get.p = newPath(meta.rootBucket, meta.buckets)
-	err := extractExpr(req.Condition, get.p)
+	cond := &getCondition{path: get.p, assigned: make(map[string]any)}
+	err := extractExpr(req.Condition, cond)
+	get.index, get.indexKey = meta.findIndex(cond)
 	return get, err
 }
 
+// getIndexed answers the items in the index value bucket that
+// also match any keys in the condition.
+func (d *genDriver) getIndexed(tx *bolt.Tx, get getData, a doc.Allocator) error {
+	b := tx.Bucket(get.index.bucketName(get.meta.rootBucket))
+	if b == nil {
+		return nil
+	}
+	vb := b.Bucket(get.indexKey)
+	if vb == nil {
+		return nil
+	}
+	c := vb.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		p := newPath(get.meta.rootBucket, get.meta.buckets)
+		err := decodeEntry(k, p)
+		if err != nil {
+			return err
+		}
+		if !p.matches(get.p) {
+			continue
+		}
+		err = getItem(tx, get.meta, d.keys, p, a)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *genDriver) Delete(req doc.DeleteRequestAny, a doc.Allocator) (*doc.Optional, error) {
 	del, err := d.prepareDelete(req, a)
 	if err != nil {
@@ -181,7 +273,7 @@ func (d *genDriver) Delete(req doc.DeleteRequestAny, a doc.Allocator) (*doc.Opti
 		}
 		for i, node := range del.p.nodes {
 			if node.leaf {
-				return b.Delete([]byte(del.key))
+				return d.deleteItem(tx, b, del, req.ItemAny())
 			}
 			b = b.Bucket(node.value)
 			if b == nil {
@@ -189,7 +281,7 @@ func (d *genDriver) Delete(req doc.DeleteRequestAny, a doc.Allocator) (*doc.Opti
 			}
 
 			if i >= len(del.p.nodes)-1 {
-				return b.Delete([]byte(del.key))
+				return d.deleteItem(tx, b, del, req.ItemAny())
 			}
 		}
 		return fmt.Errorf("Fell out of loop")
@@ -197,6 +289,28 @@ func (d *genDriver) Delete(req doc.DeleteRequestAny, a doc.Allocator) (*doc.Opti
 	return nil, err
 }
 
+// deleteItem deletes the item stored at the key in b, and
+// removes it from the indexes.
+func (d *genDriver) deleteItem(tx *bolt.Tx, b *bolt.Bucket, del deleteData, item any) error {
+	if len(del.meta.indexes) > 0 {
+		prev, err := readPrev(del.meta, d.keys, b, del.key, item)
+		if err != nil {
+			return err
+		}
+		if prev != nil {
+			entry, err := encodeEntry(del.p)
+			if err != nil {
+				return err
+			}
+			err = deleteIndexes(tx, del.meta, entry, prev)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return b.Delete([]byte(del.key))
+}
+
 type deleteData struct {
 	meta *genMetadata
 	p    *path
@@ -215,7 +329,7 @@ func (d *genDriver) prepareDelete(req doc.DeleteRequestAny, a doc.Allocator) (de
 		return del, fmt.Errorf("missing metadata for \"%v\"", tn)
 	}
 	del.p = newPath(meta.rootBucket, meta.buckets)
-	reflect.Get(item, del.p)
+	genGetFields(item, meta.DomainKeys(), del.p)
 	k, err := del.p.makeKey()
 	if err != nil {
 		return del, err
@@ -294,6 +408,7 @@ type getIterator interface {
 }
 
 func newGetIterator(meta *genMetadata,
+	keys driverkit.KeyProvider,
 	tx *bolt.Tx,
 	p *path,
 	a doc.Allocator) (getIterator, error) {
@@ -306,9 +421,10 @@ func newGetIterator(meta *genMetadata,
 	//	fmt.Println("GET PATH", *p)
 	steps := make([]wildcardIteratorStep, 0, len(p.nodes))
 	steps = append(steps, wildcardIteratorStep{})
-	req := reflect.SetRequest{FieldNames: meta.DomainKeys(),
-		NewValues: make([]any, len(meta.DomainKeys()))}
+	req := genSetFields(reflect.SetRequest{FieldNames: meta.DomainKeys(),
+		NewValues: make([]any, len(meta.DomainKeys()))})
 	return &wildcardIterator{meta: meta,
+		keys:  keys,
 		a:     a,
 		tx:    tx,
 		b:     b,
@@ -321,6 +437,7 @@ func newGetIterator(meta *genMetadata,
 // case buckets are iterated.
 type wildcardIterator struct {
 	meta *genMetadata
+	keys driverkit.KeyProvider
 	a    doc.Allocator
 	tx   *bolt.Tx
 	// root bucket
@@ -350,7 +467,7 @@ func (w *wildcardIterator) Next() any {
 // domainItem converst the key and value into a domain item.
 func (w *wildcardIterator) domainItem(k, v []byte) any {
 	//	fmt.Println("domain item", k, string(v), "path len", len(w.steps))
-	item, err := w.meta.fromDb(w.a.New(), v)
+	item, err := w.meta.fromDb(w.a.New(), v, w.keys)
 	//	fmt.Println("key", string(k), "value", string(v))
 	w.err = cmp.Or(w.err, err)
 	// Set the keys. The values should be in the steps.
@@ -698,6 +815,17 @@ func (p *path) BinaryAssignment(lhs string, rhs any) error {
 	return nil
 }
 
+// matches answers false if any value in the condition
+// path differs from my value.
+func (p *path) matches(cond *path) bool {
+	for i, n := range cond.nodes {
+		if n.value != nil && !bytes.Equal(n.value, p.nodes[i].value) {
+			return false
+		}
+	}
+	return true
+}
+
 // makeKey returns a value to be used as the key in the database.
 func (p *path) makeKey() (boltKey, error) {
 	// Validate
@@ -727,6 +855,48 @@ func (p *path) makeKey() (boltKey, error) {
 // ---------------------------------------------------------
 // MISC
 
+// getItem allocates the single item addressed by the path,
+// which must have a value for every node. Missing items
+// are ignored.
+func getItem(tx *bolt.Tx, meta *genMetadata, keys driverkit.KeyProvider, p *path, a doc.Allocator) error {
+	b := tx.Bucket([]byte(meta.rootBucket))
+	for _, node := range p.nodes {
+		if b == nil {
+			return nil
+		}
+		if node.pt == bucketType {
+			b = b.Bucket(node.value)
+		}
+	}
+	if b == nil {
+		return nil
+	}
+	key, err := p.makeKey()
+	if err != nil {
+		return err
+	}
+	v := b.Get(key)
+	if v == nil {
+		return nil
+	}
+	item, err := meta.fromDb(a.New(), v, keys)
+	if err != nil {
+		return err
+	}
+	// Set the keys from the path, the same as the iterator.
+	req := genSetFields(reflect.SetRequest{FieldNames: meta.DomainKeys(),
+		NewValues: make([]any, len(p.nodes))})
+	for i, node := range p.nodes {
+		if node.ft == stringType {
+			req.NewValues[i] = string(node.value)
+		} else if node.ft == uint64Type {
+			req.NewValues[i] = genBtoi(node.value)
+		}
+	}
+	reflect.Set(req, item)
+	return nil
+}
+
 func getAutoIncKey(flags keyFlags, root, b *bolt.Bucket) (uint64, error) {
 	if flags&FlagAutoIncLocal != 0 {
 		return b.NextSequence()
@@ -3,12 +3,33 @@ package bboltgendriver
 // autogenerated with github.com/hackborn/doc_drivers
 // do not modify
 
+type genJsonAddress struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+}
+
 type genJsonCollectionSetting struct {
 	Value []int64 `json:"value"`
 }
 
 type genJsonCompany struct {
-	Value int64 `json:"val"`
+	Ticker  string   `json:"ticker"`
+	Value   int64    `json:"val"`
+	Aliases []string `json:"aliases"`
+	Created any      `json:"created"`
+	Updated int64    `json:"updated"`
+}
+
+type genJsonContact struct {
+	Name       string `json:"name"`
+	HomeStreet string `json:"home_street"`
+	HomeCity   string `json:"home_city"`
+	Phone      string `json:"phone"`
+}
+
+type genJsonDocument struct {
+	Body    string `json:"body"`
+	Version int64  `json:"version"`
 }
 
 type genJsonEvents struct {
@@ -30,6 +51,9 @@ type genJsonFiling struct {
 	FiscalYear int    `json:"fy"`
 }
 
+type genJsonRecord struct {
+}
+
 type genJsonSkip struct {
 	Value float64 `json:"value"`
 }
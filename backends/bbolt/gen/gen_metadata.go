@@ -5,7 +5,13 @@ package bboltgendriver
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 	"sync/atomic"
+	"time"
+
+	"github.com/hackborn/doc_drivers/driverkit"
 )
 
 type genMetadataNewConvFunc func() any
@@ -14,21 +20,502 @@ type genMetadata struct {
 	rootBucket    string
 	buckets       []genKeyMetadata
 	newConvStruct genMetadataNewConvFunc
+	indexes       []genIndexMetadata
+	// defaults are the field values for records
+	// that have none stored, by field name.
+	defaults map[string]any
+	// notNull are the fields that can't be stored
+	// without a value, by field name.
+	notNull []string
+	// formats are the field formats other than json, and of
+	// every encrypted or compressed field, by field name.
+	formats map[string]string
+	// encrypted are the fields sealed with the
+	// key for the root bucket, by field name.
+	encrypted map[string]bool
+	// compressed are the compression algorithms of
+	// the compressed fields, by field name.
+	compressed map[string]string
+	// compress is the compression algorithm of
+	// the whole stored record, if any.
+	compress string
+	// flattened are the names the fields of flattened
+	// structs are stored under, by field path.
+	flattened map[string]string
+	// renamed are the names fields were stored under
+	// before they were renamed, by stored name.
+	renamed map[string][]string
+	stamps  []genStampMetadata
+	// version is the name of the version field, if any.
+	version string
 
-	dk atomic.Pointer[[]string] // List of the buckets/domainNames
+	dk    atomic.Pointer[[]string]          // List of the buckets/domainNames
+	names atomic.Pointer[map[string]string] // Stored names, by encoding/json name
 }
 
 // toDb converts a domain value for this metadata into a database
 // value. Database values are just copies of the domain value with
 // metadata appropriate for the JSON schema for the database.
-func (m *genMetadata) toDb(src any) (any, error) {
-	return src, nil
+// Encrypted fields are sealed with the keys.
+func (m *genMetadata) toDb(src any, keys driverkit.KeyProvider) (any, error) {
+	v := reflect.Indirect(reflect.ValueOf(src))
+	names := m.storedNames(v.Type())
+	if len(m.formats) < 1 && len(m.flattened) < 1 && len(names) < 1 {
+		return src, nil
+	}
+	dat, err := json.Marshal(src)
+	if err != nil {
+		return nil, err
+	}
+	dst := make(map[string]json.RawMessage)
+	err = json.Unmarshal(dat, &dst)
+	if err != nil {
+		return nil, err
+	}
+	dst = genRenameJson(dst, names)
+	if err := m.flatten(v, dst); err != nil {
+		return nil, err
+	}
+	for name, format := range m.formats {
+		fv, jsonName, ok := m.field(v, name)
+		if !ok {
+			return nil, fmt.Errorf("Missing field \"%v\"", name)
+		}
+		f, err := driverkit.FindFormat(format)
+		if err != nil {
+			return nil, err
+		}
+		b, err := f.Marshal(fv.Interface())
+		if err != nil {
+			return nil, err
+		}
+		if b, err = m.encodeField(name, jsonName, b, keys); err != nil {
+			return nil, err
+		}
+		// Formatted values are stored as base64 strings.
+		dst[jsonName], err = json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// flatten replaces the flattened structs in dst, the json
+// of the domain value v, with their fields.
+func (m *genMetadata) flatten(v reflect.Value, dst map[string]json.RawMessage) error {
+	removed := make(map[string]bool)
+	for name, jsonName := range m.flattened {
+		top, _, _ := strings.Cut(name, ".")
+		if !removed[top] {
+			removed[top] = true
+			sf, ok := v.Type().FieldByName(top)
+			if !ok {
+				return fmt.Errorf("Missing field \"%v\"", top)
+			}
+			if err := genDeleteJson(dst, sf, v.FieldByIndex(sf.Index)); err != nil {
+				return err
+			}
+		}
+		fv := genFieldByPath(v, name)
+		if !fv.IsValid() {
+			return fmt.Errorf("Missing field \"%v\"", name)
+		}
+		var err error
+		dst[jsonName], err = json.Marshal(fv.Interface())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// genDeleteJson deletes the field from dst. encoding/json
+// writes the fields of untagged embedded structs at the top.
+func genDeleteJson(dst map[string]json.RawMessage, sf reflect.StructField, fv reflect.Value) error {
+	if !sf.Anonymous || sf.Tag.Get("json") != "" {
+		delete(dst, genJsonName(sf))
+		return nil
+	}
+	dat, err := json.Marshal(fv.Interface())
+	if err != nil {
+		return err
+	}
+	embedded := make(map[string]json.RawMessage)
+	err = json.Unmarshal(dat, &embedded)
+	for name := range embedded {
+		delete(dst, name)
+	}
+	return err
+}
+
+// field answers the field at the path, and the
+// name of the json it's stored under.
+func (m *genMetadata) field(v reflect.Value, name string) (reflect.Value, string, bool) {
+	if jsonName, ok := m.flattened[name]; ok {
+		fv := genFieldByPath(v, name)
+		return fv, jsonName, fv.IsValid()
+	}
+	sf, ok := v.Type().FieldByName(name)
+	if !ok {
+		return reflect.Value{}, "", false
+	}
+	jsonName := genJsonName(sf)
+	if stored, ok := m.storedNames(v.Type())[jsonName]; ok {
+		jsonName = stored
+	}
+	return v.FieldByIndex(sf.Index), jsonName, true
+}
+
+// storedNames answers the names the fields are stored under, from
+// the tags of the json struct, by their encoding/json name. Only
+// the names that differ are included. Keys aren't in the json
+// struct, and the fields of nested structs aren't renamed, so
+// both keep their encoding/json names.
+func (m *genMetadata) storedNames(t reflect.Type) map[string]string {
+	if p := m.names.Load(); p != nil {
+		return *p
+	}
+	names := make(map[string]string)
+	if m.newConvStruct != nil {
+		ct := reflect.Indirect(reflect.ValueOf(m.newConvStruct())).Type()
+		for i := 0; i < ct.NumField(); i++ {
+			cf := ct.Field(i)
+			// Flattened fields have no field of the same name.
+			sf, ok := t.FieldByName(cf.Name)
+			if !ok {
+				continue
+			}
+			if from, to := genJsonName(sf), genJsonName(cf); from != to {
+				names[from] = to
+			}
+		}
+	}
+	m.names.Store(&names)
+	return names
+}
+
+// genRenameJson answers the json with the names replaced.
+func genRenameJson(src map[string]json.RawMessage, names map[string]string) map[string]json.RawMessage {
+	if len(names) < 1 {
+		return src
+	}
+	dst := make(map[string]json.RawMessage, len(src))
+	for name, raw := range src {
+		if to, ok := names[name]; ok {
+			name = to
+		}
+		dst[name] = raw
+	}
+	return dst
 }
 
 // fromDb reads raw database data into a domain struct.
-func (m *genMetadata) fromDb(dst any, dbdata []byte) (any, error) {
-	err := json.Unmarshal(dbdata, dst)
-	return dst, err
+// Encrypted fields are opened with the keys.
+func (m *genMetadata) fromDb(dst any, dbdata []byte, keys driverkit.KeyProvider) (any, error) {
+	if err := m.setDefaults(dst); err != nil {
+		return nil, err
+	}
+	v := reflect.Indirect(reflect.ValueOf(dst))
+	names := m.storedNames(v.Type())
+	if len(m.formats) < 1 && len(m.flattened) < 1 && len(m.renamed) < 1 && len(names) < 1 && m.compress == "" {
+		err := json.Unmarshal(dbdata, dst)
+		return dst, err
+	}
+	src, err := m.unmarshalStored(dbdata)
+	if err != nil {
+		return nil, err
+	}
+	// Pull out the formatted and flattened values
+	// before reading the rest.
+	pulled := make(map[string]json.RawMessage)
+	pull := func(name string) error {
+		_, jsonName, ok := m.field(v, name)
+		if !ok {
+			return fmt.Errorf("Missing field \"%v\"", name)
+		}
+		if raw, ok := src[jsonName]; ok {
+			pulled[name] = raw
+			delete(src, jsonName)
+		}
+		return nil
+	}
+	for name := range m.formats {
+		if err := pull(name); err != nil {
+			return nil, err
+		}
+	}
+	for name := range m.flattened {
+		if err := pull(name); err != nil {
+			return nil, err
+		}
+	}
+	// The rest are read by their encoding/json names.
+	domainNames := make(map[string]string, len(names))
+	for from, to := range names {
+		domainNames[to] = from
+	}
+	dat, err := json.Marshal(genRenameJson(src, domainNames))
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(dat, dst)
+	if err != nil {
+		return nil, err
+	}
+	for name, raw := range pulled {
+		fv, jsonName, _ := m.field(v, name)
+		format, ok := m.formats[name]
+		if !ok {
+			if err := json.Unmarshal(raw, fv.Addr().Interface()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var b []byte
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return nil, err
+		}
+		if b, err = m.decodeField(name, jsonName, b, keys); err != nil {
+			return nil, err
+		}
+		f, err := driverkit.FindFormat(format)
+		if err != nil {
+			return nil, err
+		}
+		err = f.Unmarshal(b, fv.Addr().Interface())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// encodeField compresses and then seals the formatted value
+// of the field, as required. Sealed data doesn't compress, and
+// is bound to jsonName, the name the field is stored under.
+func (m *genMetadata) encodeField(name, jsonName string, b []byte, keys driverkit.KeyProvider) ([]byte, error) {
+	var err error
+	if compression, ok := m.compressed[name]; ok {
+		if b, err = genCompress(compression, b); err != nil {
+			return nil, err
+		}
+	}
+	if m.encrypted[name] {
+		return genSeal(keys, m.rootBucket, jsonName, b)
+	}
+	return b, nil
+}
+
+// decodeField reverses encodeField.
+func (m *genMetadata) decodeField(name, jsonName string, b []byte, keys driverkit.KeyProvider) ([]byte, error) {
+	var err error
+	if m.encrypted[name] {
+		if b, err = genOpen(keys, m.rootBucket, jsonName, m.renamed[jsonName], b); err != nil {
+			return nil, err
+		}
+	}
+	if compression, ok := m.compressed[name]; ok {
+		return genDecompress(compression, b)
+	}
+	return b, nil
+}
+
+// encodeStored answers the stored record, compressed if
+// the whole record is compressed.
+func (m *genMetadata) encodeStored(value []byte) ([]byte, error) {
+	if m.compress == "" {
+		return value, nil
+	}
+	return genCompress(m.compress, value)
+}
+
+// unmarshalStored answers the json of a stored record. Fields
+// that were renamed are moved from their previous names, if
+// the record is from before the rename.
+func (m *genMetadata) unmarshalStored(dbdata []byte) (map[string]json.RawMessage, error) {
+	var err error
+	if m.compress != "" {
+		if dbdata, err = genDecompress(m.compress, dbdata); err != nil {
+			return nil, err
+		}
+	}
+	src := make(map[string]json.RawMessage)
+	err = json.Unmarshal(dbdata, &src)
+	if err != nil {
+		return nil, err
+	}
+	for jsonName, was := range m.renamed {
+		if _, ok := src[jsonName]; ok {
+			continue
+		}
+		for _, name := range was {
+			if raw, ok := src[name]; ok {
+				src[jsonName] = raw
+				delete(src, name)
+				break
+			}
+		}
+	}
+	return src, nil
+}
+
+// stamp answers the stored value with the timestamps set to now.
+// Created timestamps keep the value in prev, the stored record,
+// if there is one.
+func (m *genMetadata) stamp(value, prev []byte, item any, now time.Time) ([]byte, error) {
+	dst := make(map[string]json.RawMessage)
+	err := json.Unmarshal(value, &dst)
+	if err != nil {
+		return nil, err
+	}
+	var old map[string]json.RawMessage
+	if prev != nil {
+		old, err = m.unmarshalStored(prev)
+		if err != nil {
+			return nil, err
+		}
+	}
+	v := reflect.Indirect(reflect.ValueOf(item))
+	for _, s := range m.stamps {
+		fv, jsonName, ok := m.field(v, s.domainName)
+		if !ok {
+			return nil, fmt.Errorf("Missing field \"%v\"", s.domainName)
+		}
+		if raw, ok := old[jsonName]; ok && s.created {
+			dst[jsonName] = raw
+			continue
+		}
+		// Timestamps are either a time.Time or
+		// milliseconds since the epoch.
+		var v any = now.UnixMilli()
+		if fv.Type() == reflect.TypeOf(now) {
+			v = now
+		}
+		dst[jsonName], err = json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(dst)
+}
+
+// setVersion answers the stored value with the next version,
+// if the item has the version in prev, the stored record.
+// Records that aren't stored are version 0.
+func (m *genMetadata) setVersion(value, prev []byte, item any) ([]byte, error) {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	fv, jsonName, ok := m.field(v, m.version)
+	if !ok {
+		return nil, fmt.Errorf("Missing field \"%v\"", m.version)
+	}
+	version, err := genVersionValue(fv)
+	if err != nil {
+		return nil, err
+	}
+	var stored int64
+	if prev != nil {
+		old, err := m.unmarshalStored(prev)
+		if err != nil {
+			return nil, err
+		}
+		if raw, ok := old[jsonName]; ok {
+			err = json.Unmarshal(raw, &stored)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if version != stored {
+		return nil, &driverkit.ConflictError{Table: m.rootBucket, Version: version}
+	}
+	dst := make(map[string]json.RawMessage)
+	err = json.Unmarshal(value, &dst)
+	if err != nil {
+		return nil, err
+	}
+	dst[jsonName], err = json.Marshal(version + 1)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(dst)
+}
+
+// nextVersion copies the item into dst, with the version
+// field set to the version it was stored with.
+func (m *genMetadata) nextVersion(dst, item any) error {
+	v := reflect.ValueOf(dst).Elem()
+	v.Set(reflect.Indirect(reflect.ValueOf(item)))
+	fv, _, ok := m.field(v, m.version)
+	if !ok {
+		return fmt.Errorf("Missing field \"%v\"", m.version)
+	}
+	version, err := genVersionValue(fv)
+	if err != nil {
+		return err
+	}
+	if fv.CanInt() {
+		fv.SetInt(version + 1)
+	} else {
+		fv.SetUint(uint64(version + 1))
+	}
+	return nil
+}
+
+// genVersionValue answers the integer version as an int64.
+func genVersionValue(v reflect.Value) (int64, error) {
+	switch {
+	case v.CanInt():
+		return v.Int(), nil
+	case v.CanUint():
+		return int64(v.Uint()), nil
+	default:
+		return 0, fmt.Errorf("Version must be an integer, has %v", v.Type())
+	}
+}
+
+// genJsonName answers the name encoding/json uses for the field.
+func genJsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+// checkNotNull answers an error if a notnull field of the
+// item is nil, since it would be stored as null. Fields that
+// can't be nil always have a value.
+func (m *genMetadata) checkNotNull(item any) error {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	for _, name := range m.notNull {
+		f := genFieldByPath(v, name)
+		if !f.IsValid() {
+			return fmt.Errorf("Missing field \"%v\"", name)
+		}
+		switch f.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+			if f.IsNil() {
+				return fmt.Errorf("Field \"%v\" is notnull but has no value", name)
+			}
+		}
+	}
+	return nil
+}
+
+// setDefaults sets the default values in the domain struct.
+// Fields that are stored replace them when the data is read,
+// so only records from before a field existed keep the default.
+func (m *genMetadata) setDefaults(dst any) error {
+	v := reflect.Indirect(reflect.ValueOf(dst))
+	for name, def := range m.defaults {
+		f := genFieldByPath(v, name)
+		if !f.CanSet() {
+			return fmt.Errorf("Can't set default for \"%v\"", name)
+		}
+		f.Set(reflect.ValueOf(def).Convert(f.Type()))
+	}
+	return nil
 }
 
 /*
@@ -62,6 +549,15 @@ func (m *genMetadata) DomainKeys() []string {
 	return p
 }
 
+type genStampMetadata struct {
+	// domainName is the name of the timestamp field.
+	domainName string
+
+	// created is true if the field is only set when the
+	// record is new, otherwise it's set on every write.
+	created bool
+}
+
 type genKeyMetadata struct {
 	// domainName is the name of the field struct.
 	domainName string
@@ -12,6 +12,7 @@ import (
 	bboltgendriver "github.com/hackborn/doc_drivers/backends/bbolt/gen"
 	"github.com/hackborn/doc_drivers/backends/bbolt/nodes"
 	bboltrefdriver "github.com/hackborn/doc_drivers/backends/bbolt/ref"
+	"github.com/hackborn/doc_drivers/driverkit"
 	"github.com/hackborn/doc_drivers/graphs"
 	"github.com/hackborn/doc_drivers/registry"
 )
@@ -41,6 +42,12 @@ func addGraphs(m map[string]graphs.Entry) {
 	for k, v := range entries {
 		m[k] = v
 	}
+	// Internal graphs are run by MakeDriver.
+	entries, _ = graphs.ReadEntries(graphsFs, "graphs/internal/*.txt")
+	for k, v := range entries {
+		v.Hidden = true
+		m[k] = v
+	}
 }
 
 func newOpenFunc(registry.Factory) func() error {
@@ -49,11 +56,11 @@ func newOpenFunc(registry.Factory) func() error {
 
 		// Make drivers accessible to nodes without going through the backend
 		refFn := func() doc.Driver {
-			inner := bboltrefdriver.NewDriver(nodes.FormatBbolt)
+			inner := bboltrefdriver.NewDriverWithKeys(nodes.FormatBbolt, driverkit.StaticKey(devKey))
 			return &openingDriver{inner: inner}
 		}
 		genFn := func() doc.Driver {
-			inner := bboltgendriver.NewDriver(nodes.FormatBbolt)
+			inner := bboltgendriver.NewDriverWithKeys(nodes.FormatBbolt, driverkit.StaticKey(devKey))
 			return &openingDriver{inner: inner}
 		}
 
@@ -104,7 +111,10 @@ func (d *openingDriver) Delete(req doc.DeleteRequestAny, a doc.Allocator) (*doc.
 	panic("should not be called")
 }
 
-//go:embed graphs/*
+// devKey seals the encrypted fields of the development drivers.
+var devKey = []byte("doc_drivers development key 0001")
+
+//go:embed graphs/* graphs/internal/*
 var graphsFs embed.FS
 
 //go:embed ref/*
@@ -1,5 +1,17 @@
 package nodes
 
+import (
+	"github.com/hackborn/doc_drivers/enc"
+)
+
 const (
 	FormatBbolt = "bbolt"
 )
+
+var (
+	// tagSupport is every tag keyword and flag bbolt honors.
+	tagSupport = enc.Support{Backend: FormatBbolt,
+		Keywords: []string{"name", "key", "format", "autoinc", "index", "unique", "default", "notnull", "nullable", "created", "updated", "version", "inline", "flatten", "was", "encrypt", "compress"},
+		Flags:    enc.FlagAutoIncGlobal | enc.FlagAutoIncLocal,
+	}
+)
@@ -13,6 +13,8 @@ import (
 	"golang.org/x/text/language"
 
 	"github.com/hackborn/doc_drivers/enc"
+	"github.com/hackborn/doc_drivers/registry"
+	"github.com/hackborn/doc_drivers/schema"
 	"github.com/hackborn/onefunc/errors"
 	"github.com/hackborn/onefunc/pipeline"
 )
@@ -42,25 +44,37 @@ type goNodeSharedData struct {
 
 	// Various configurable properties.
 	Flags string
+
+	// Naming converts the bucket and field names that
+	// aren't tagged. If empty, names are unchanged, or
+	// lowercased with the "lowercase" flag.
+	Naming string
+
+	// If true, a hash of the inputs is added to the
+	// header of every generated file.
+	InputHash bool
 }
 
 type goNodeData struct {
 	goNodeSharedData
 
-	casingFn casingFunc
+	// naming is the default for every struct, from
+	// Naming or the "lowercase" flag.
+	naming string
 
 	// Building -- this is the generated data that is
 	// waiting to get flushed.
 	templates map[string]string
 	metadata  []MetadataDef
 	json      []JsonDef
+	structs   []*pipeline.StructData
 	// Store any structs that get new names, so we can
 	// replace.
 	jsonRenames map[string]string
 }
 
 func (n *goNode) Start(input pipeline.StartInput) error {
-	data := goNodeData{casingFn: casingPassthrough}
+	data := goNodeData{}
 	data.jsonRenames = make(map[string]string)
 	data.goNodeSharedData = n.goNodeSharedData
 	input.SetNodeData(&data)
@@ -71,27 +85,28 @@ func (n *goNode) Run(state *pipeline.State, input pipeline.RunInput, output *pip
 	if err := n.initTemplates(state); err != nil {
 		return err
 	}
-	eb := &errors.FirstBlock{}
+	eb := &enc.ListBlock{}
 	data := state.NodeData.(*goNodeData)
-	if strings.Contains(strings.ToLower(data.Flags), "lowercase") {
-		data.casingFn = casingLower
+	if err := enc.CheckNaming(data.Naming); err != nil {
+		return err
+	}
+	data.naming = data.Naming
+	if data.naming == "" && strings.Contains(strings.ToLower(data.Flags), "lowercase") {
+		data.naming = enc.NamingLower
 	}
 	for _, pin := range input.Pins {
 		switch p := pin.Payload.(type) {
 		case *pipeline.StructData:
+			data.structs = append(data.structs, p)
 			eb.AddError(n.runStructPin(data, p))
 		}
 	}
-	return eb.Err
+	return eb.Err()
 }
 
 func (n *goNode) Flush(state *pipeline.State, output *pipeline.RunOutput) error {
 	data := state.NodeData.(*goNodeData)
 	n.flushRenames(data)
-	err := n.flushValidate(data)
-	if err != nil {
-		return err
-	}
 	vars, err := n.flushMakeVars(data)
 	if err != nil {
 		return fmt.Errorf("go node err: %w", err)
@@ -102,7 +117,17 @@ func (n *goNode) Flush(state *pipeline.State, output *pipeline.RunOutput) error
 		return fmt.Errorf("go node makeTemplates err: %w", err)
 	}
 
-	return err
+	// Metadata is sorted by flushMakeVars.
+	sch := &schema.Schema{Format: FormatBbolt}
+	for _, md := range data.metadata {
+		sch.Tables = append(sch.Tables, md.schemaTable())
+	}
+	manifest, err := sch.Manifest()
+	if err != nil {
+		return fmt.Errorf("go node manifest err: %w", err)
+	}
+	output.Pins = append(output.Pins, pipeline.Pin{Payload: manifest}, pipeline.Pin{Payload: sch})
+	return nil
 }
 
 func (n *goNode) initTemplates(state *pipeline.State) error {
@@ -141,6 +166,10 @@ func (n *goNode) runStructPin(data *goNodeData, pin *pipeline.StructData) error
 		// Even if skip is true we will still add the json def, because
 		// it might be used as a field inside an included metadate object.
 		data.metadata = append(data.metadata, md)
+		// Validation is only meaningful on a complete definition.
+		if err == nil {
+			err = md.Validate()
+		}
 	}
 	data.json = append(data.json, jd)
 	return err
@@ -149,32 +178,46 @@ func (n *goNode) runStructPin(data *goNodeData, pin *pipeline.StructData) error
 func (n *goNode) runMetadataDef(data *goNodeData, pin *pipeline.StructData) (MetadataDef, JsonDef, error) {
 	md := MetadataDef{DomainName: pin.Name}
 	jd := JsonDef{Name: data.Prefix + "Json" + pin.Name}
-	md.RootBucket = data.casingFn(pin.Name)
 	md.NewConvStruct = jd.Name
 	data.jsonRenames[pin.Name] = jd.Name
+	// Collect every error, so one run reports all the problems.
+	eb := &enc.ListBlock{}
+	naming := n.runTableTags(data, pin, &md, eb)
 
 	for _, field := range pin.Fields {
-		jf := JsonFieldDef{Name: field.Name, Type: field.RawType}
+		// Fields of flattened structs have a path, i.e. "Address.City".
+		jf := JsonFieldDef{Name: strings.ReplaceAll(field.Name, ".", ""), Type: field.RawType}
+		// The json file has no imports, so types
+		// from other packages are left untyped.
+		if strings.Contains(jf.Type, ".") {
+			jf.Type = "any"
+		}
 		// Default JSON tag. It may be replaced or cleared according
 		// to the following rules.
-		jsonTag := data.casingFn(field.Name)
+		jsonTag := enc.ApplyNaming(naming, baseFieldName(field.Name))
+		format, def, compress := "", "", ""
+		var flags, was []string
+		var extra map[string]any
 		if field.Tag != "" {
 			pt, err := enc.ParseTag(field.Tag)
-			err = cmp.Or(err, pt.Validate())
+			err = cmp.Or(err, pt.Validate(), pt.CheckType(field.RawType), tagSupport.Check(pt))
 			if err != nil {
-				return md, jd, err
+				eb.AddError(enc.NewFieldError(pin.Name, field.Name, err))
+				continue
 			}
 			if pt.Name == "-" {
 				// Omit this field from the DB.
 				continue
 			} else if pt.HasKey {
 				if pt.Autoinc() && field.RawType != "uint64" {
-					return md, jd, fmt.Errorf("Autoinc must be on uint64 type (%v/%v)", pin.Name, field.Name)
+					eb.AddError(enc.NewFieldError(pin.Name, field.Name, fmt.Errorf("Autoinc must be on uint64 type")))
+					continue
 				}
-				boltName := data.casingFn(field.Name)
+				boltName := enc.ApplyNaming(naming, baseFieldName(field.Name))
 				if pt.Name != "" {
 					boltName = pt.Name
 				}
+				boltName = pt.Flatten + boltName
 				ft := "stringType"
 				if field.RawType == "uint64" {
 					ft = "uint64Type"
@@ -187,35 +230,141 @@ func (n *goNode) runMetadataDef(data *goNodeData, pin *pipeline.StructData) (Met
 					keyInfo:  &keyInfo,
 				}
 				md.Buckets = append(md.Buckets, key)
-				// Since this is a key it shouldn't be in the json
+				addIndexes(&md, pt, field.Name, boltName)
+				addUniques(&md, pt, field.Name, boltName)
+				md.columns = append(md.columns, schema.Column{Field: field.Name,
+					Name:   boltName,
+					Type:   field.RawType,
+					DbType: strings.TrimSuffix(ft, "Type"),
+					Format: pt.Format,
+					Flags:  append([]string{"key"}, columnFlags(pt)...),
+					Extra:  pt.Extra,
+					Was:    pt.Was,
+				})
+				// Since this is a key it shouldn't be in the json,
+				// but a flattened key is moved with its struct.
+				if strings.Contains(field.Name, ".") {
+					md.Flattened = append(md.Flattened, MetadataFlattenDef{DomainName: field.Name, JsonName: boltName})
+				}
 				jsonTag = ""
 			} else {
 				// Json tag has been assigned.
 				if pt.Name != "" {
 					jsonTag = pt.Name
 				}
+				jsonTag = pt.Flatten + jsonTag
+				format = pt.Format
+				// Encrypted and compressed fields are encoded from
+				// their format, which is json unless the tag sets one.
+				if pt.Encrypt || pt.Compress != "" {
+					format = cmp.Or(format, "json")
+				}
+				if pt.Encrypt {
+					md.Encrypted = append(md.Encrypted, field.Name)
+				}
+				if pt.Compress != "" {
+					compress = pt.Compress
+					md.Compressed = append(md.Compressed, MetadataCompressDef{DomainName: field.Name, Compress: compress})
+				}
+				if (format != "" && format != "json") || pt.Encrypt || pt.Compress != "" {
+					md.Formats = append(md.Formats, MetadataFormatDef{DomainName: field.Name, Format: format})
+				}
+				extra = pt.Extra
+				flags = columnFlags(pt)
+				was = pt.Was
+				if pt.HasDefault {
+					v, err := pt.DefaultValue(field.Type)
+					if err != nil {
+						eb.AddError(enc.NewFieldError(pin.Name, field.Name, err))
+						continue
+					}
+					md.Defaults = append(md.Defaults, newMetadataDefaultDef(field.Name, v))
+					def = fmt.Sprint(v)
+				}
+				if pt.NotNull {
+					md.NotNull = append(md.NotNull, field.Name)
+				}
+				if pt.Created || pt.Updated {
+					md.Stamps = append(md.Stamps, MetadataStampDef{DomainName: field.Name, Created: pt.Created})
+				}
+				if pt.Version {
+					if md.Version != "" {
+						eb.AddError(enc.NewStructError(pin.Name, fmt.Errorf("Metadata can only have one version field")))
+					}
+					md.Version = field.Name
+				}
+				addIndexes(&md, pt, field.Name, jsonTag)
+				addUniques(&md, pt, field.Name, jsonTag)
 			}
 		}
-		// If there's no json tag, don't need a json field
+		// If there's no json tag, don't need a json field. Records
+		// are stored under the tags of the json struct, so the json
+		// tag is the stored name everywhere it's used.
 		if jsonTag != "" {
+			if strings.Contains(field.Name, ".") {
+				md.Flattened = append(md.Flattened, MetadataFlattenDef{DomainName: field.Name, JsonName: jsonTag})
+			}
+			if len(was) > 0 {
+				md.Renamed = append(md.Renamed, MetadataRenameDef{JsonName: jsonTag, Was: was})
+			}
 			jf.Tag = "`json:" + `"` + jsonTag + `"` + "`"
 			jd.Fields = append(jd.Fields, jf)
+			md.columns = append(md.columns, schema.Column{Field: field.Name, Name: jsonTag, Type: field.RawType, DbType: "json", Format: format, Flags: flags, Default: def, Extra: extra, Was: was, Compress: compress})
+		}
+	}
+
+	sortIndexes(&md)
+
+	// Two fields can't share a name.
+	used := make(map[string]string)
+	for _, col := range md.columns {
+		if prev, ok := used[col.Name]; ok {
+			eb.AddError(enc.NewFieldError(pin.Name, col.Field, fmt.Errorf("Column \"%v\" is already used by %v", col.Name, prev)))
+			continue
 		}
+		used[col.Name] = col.Field
 	}
+	// A previous name can't still be in use.
+	renamed := make(map[string]string)
+	for _, col := range md.columns {
+		for _, was := range col.Was {
+			if prev, ok := used[was]; ok {
+				eb.AddError(enc.NewFieldError(pin.Name, col.Field, fmt.Errorf("Was \"%v\" is the column of %v", was, prev)))
+			} else if prev, ok := renamed[was]; ok {
+				eb.AddError(enc.NewFieldError(pin.Name, col.Field, fmt.Errorf("Was \"%v\" is already renamed to %v", was, prev)))
+			}
+			renamed[was] = col.Name
+		}
+	}
+
+	return md, jd, eb.Err()
+}
 
+// runTableTags sets the root bucket from the table tags,
+// answering the naming for the fields, which the table
+// tag can override.
+func (n *goNode) runTableTags(data *goNodeData, pin *pipeline.StructData, md *MetadataDef, eb *enc.ListBlock) string {
+	naming, name := data.naming, ""
 	for _, field := range pin.UnexportedFields {
 		if field.Tag != "" {
 			pt, err := enc.ParseTag(field.Tag)
 			if err != nil {
-				return md, jd, err
+				eb.AddError(enc.NewFieldError(pin.Name, field.Name, err))
+				continue
 			}
 			if pt.Name != "" {
-				md.RootBucket = pt.Name
+				name = pt.Name
+			}
+			if pt.Naming != "" {
+				naming = pt.Naming
+			}
+			if pt.Compress != "" {
+				md.Compress = pt.Compress
 			}
 		}
 	}
-
-	return md, jd, nil
+	md.RootBucket = cmp.Or(name, enc.ApplyNaming(naming, pin.Name))
+	return naming
 }
 
 func (n *goNode) flushRenames(data *goNodeData) {
@@ -229,16 +378,6 @@ func (n *goNode) flushRenames(data *goNodeData) {
 	}
 }
 
-func (n *goNode) flushValidate(nodeData *goNodeData) error {
-	for _, m := range nodeData.metadata {
-		err := m.Validate()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 func (n *goNode) flushMakeVars(nodeData *goNodeData) (map[string]any, error) {
 	if nodeData.Pkg == "" {
 		return nil, fmt.Errorf("Requires package name (set Pkg= on node)")
@@ -254,7 +393,7 @@ func (n *goNode) flushMakeVars(nodeData *goNodeData) (map[string]any, error) {
 		return strings.Compare(a.DomainName, b.DomainName)
 	})
 	for i, md := range nodeData.metadata {
-		slices.SortFunc(md.Buckets, func(a, b MetadataKeyDef) int {
+		slices.SortStableFunc(md.Buckets, func(a, b MetadataKeyDef) int {
 			return compareKeys(a.keyInfo, b.keyInfo)
 		})
 		// Autoincs are always at the tail.
@@ -272,8 +411,19 @@ func (n *goNode) flushTemplates(nodeData *goNodeData, vars map[string]any, outpu
 	if prefix != "" {
 		prefix += "_"
 	}
-	for k, v := range nodeData.templates {
-		b, err := n.runTemplate(v, vars)
+	hash := ""
+	if nodeData.InputHash {
+		settings := []string{nodeData.Pkg, nodeData.Prefix, nodeData.TablePrefix, nodeData.Flags, nodeData.Naming}
+		hash = registry.InputHash(settings, nodeData.structs)
+	}
+	// Sorted so the output is identical between runs.
+	names := make([]string, 0, len(nodeData.templates))
+	for k := range nodeData.templates {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	for _, k := range names {
+		b, err := n.runTemplate(nodeData.templates[k], vars)
 		eb.AddError(err)
 		b, err = n.runFormat(b)
 		if err != nil {
@@ -282,7 +432,11 @@ func (n *goNode) flushTemplates(nodeData *goNodeData, vars map[string]any, outpu
 		eb.AddError(err)
 		if err == nil {
 			name := prefix + k + ".go"
-			output.Pins = append(output.Pins, pipeline.Pin{Payload: &pipeline.ContentData{Name: name, Data: string(b)}})
+			content := string(b)
+			if hash != "" {
+				content = registry.AddInputHash(content, hash)
+			}
+			output.Pins = append(output.Pins, pipeline.Pin{Payload: &pipeline.ContentData{Name: name, Data: content}})
 		}
 	}
 	return eb.Err
@@ -319,12 +473,86 @@ func renameType(typename string, renames map[string]string) string {
 	return ""
 }
 
-type casingFunc func(string) string
+// baseFieldName answers the last name in the field path.
+func baseFieldName(field string) string {
+	return field[strings.LastIndex(field, ".")+1:]
+}
 
-func casingLower(s string) string {
-	return strings.ToLower(s)
+// columnFlags answers the flags for the schema, in their tag form.
+func columnFlags(pt enc.Tag) []string {
+	flags := pt.Flags.Names()
+	if pt.NotNull {
+		flags = append(flags, "notnull")
+	}
+	if pt.Nullable {
+		flags = append(flags, "nullable")
+	}
+	if pt.Created {
+		flags = append(flags, "created")
+	}
+	if pt.Updated {
+		flags = append(flags, "updated")
+	}
+	if pt.Version {
+		flags = append(flags, "version")
+	}
+	if pt.Encrypt {
+		flags = append(flags, "encrypt")
+	}
+	return flags
+}
+
+// addIndexes adds the field to every index in the tag.
+// Unnamed indexes are named after the field.
+func addIndexes(md *MetadataDef, pt enc.Tag, domainName, boltName string) {
+	for _, it := range pt.Indexes {
+		name := cmp.Or(it.Name, boltName)
+		field := MetadataIndexFieldDef{DomainName: domainName, BoltName: boltName, index: it.Index}
+		idx := slices.IndexFunc(md.Indexes, func(d MetadataIndexDef) bool {
+			return d.Name == name && !d.Constraint
+		})
+		if idx < 0 {
+			md.Indexes = append(md.Indexes, MetadataIndexDef{Name: name})
+			idx = len(md.Indexes) - 1
+		}
+		md.Indexes[idx].Unique = md.Indexes[idx].Unique || it.Unique
+		md.Indexes[idx].Fields = append(md.Indexes[idx].Fields, field)
+	}
+}
+
+// addUniques adds the field to every unique constraint in the
+// tag. Unnamed constraints are named after the field, and
+// compound constraints are in field order.
+func addUniques(md *MetadataDef, pt enc.Tag, domainName, boltName string) {
+	for _, u := range pt.Uniques {
+		name := cmp.Or(u, boltName)
+		field := MetadataIndexFieldDef{DomainName: domainName, BoltName: boltName}
+		idx := slices.IndexFunc(md.Indexes, func(d MetadataIndexDef) bool {
+			return d.Name == name && d.Constraint
+		})
+		if idx < 0 {
+			md.Indexes = append(md.Indexes, MetadataIndexDef{Name: name, Unique: true, Constraint: true})
+			idx = len(md.Indexes) - 1
+		}
+		md.Indexes[idx].Fields = append(md.Indexes[idx].Fields, field)
+	}
 }
 
-func casingPassthrough(s string) string {
-	return s
+// sortIndexes orders the indexes by name, then the constraints
+// by name, and their fields by position.
+func sortIndexes(md *MetadataDef) {
+	slices.SortFunc(md.Indexes, func(a, b MetadataIndexDef) int {
+		if a.Constraint != b.Constraint {
+			if a.Constraint {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	for _, idx := range md.Indexes {
+		slices.SortStableFunc(idx.Fields, func(a, b MetadataIndexFieldDef) int {
+			return cmp.Compare(a.index, b.index)
+		})
+	}
 }
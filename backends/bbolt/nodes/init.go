@@ -5,7 +5,7 @@ import (
 )
 
 func RegisterNodes() {
-	pipeline.RegisterNode("go", func() pipeline.Node {
+	pipeline.RegisterNode(FormatBbolt+"go", func() pipeline.Node {
 		return newGoNode()
 	})
 }
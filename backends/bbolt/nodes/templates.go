@@ -209,5 +209,70 @@ const (
 		"{{end}}" +
 		"			},\n" +
 		"			newConvStruct: func() any { return &{{.NewConvStruct}}{} },\n" +
+		"{{if .Indexes}}" +
+		"			indexes: []{{$.Prefix}}IndexMetadata{\n" +
+		"{{range .Indexes}}" +
+		"				{name: \"{{.Name}}\", unique: {{.Unique}}, constraint: {{.Constraint}}, domainNames: []string{ {{- .DomainNames -}} }, boltNames: []string{ {{- .BoltNames -}} }},\n" +
+		"{{end}}" +
+		"			},\n" +
+		"{{end}}" +
+		"{{if .Defaults}}" +
+		"			defaults: map[string]any{\n" +
+		"{{range .Defaults}}" +
+		"				\"{{.DomainName}}\": {{.Value}},\n" +
+		"{{end}}" +
+		"			},\n" +
+		"{{end}}" +
+		"{{if .NotNull}}" +
+		"			notNull: []string{ {{- range $i, $name := .NotNull}}{{if $i}}, {{end}}\"{{$name}}\"{{end -}} },\n" +
+		"{{end}}" +
+		"{{if .Formats}}" +
+		"			formats: map[string]string{\n" +
+		"{{range .Formats}}" +
+		"				\"{{.DomainName}}\": \"{{.Format}}\",\n" +
+		"{{end}}" +
+		"			},\n" +
+		"{{end}}" +
+		"{{if .Encrypted}}" +
+		"			encrypted: map[string]bool{\n" +
+		"{{range .Encrypted}}" +
+		"				\"{{.}}\": true,\n" +
+		"{{end}}" +
+		"			},\n" +
+		"{{end}}" +
+		"{{if .Compressed}}" +
+		"			compressed: map[string]string{\n" +
+		"{{range .Compressed}}" +
+		"				\"{{.DomainName}}\": \"{{.Compress}}\",\n" +
+		"{{end}}" +
+		"			},\n" +
+		"{{end}}" +
+		"{{if .Flattened}}" +
+		"			flattened: map[string]string{\n" +
+		"{{range .Flattened}}" +
+		"				\"{{.DomainName}}\": \"{{.JsonName}}\",\n" +
+		"{{end}}" +
+		"			},\n" +
+		"{{end}}" +
+		"{{if .Renamed}}" +
+		"			renamed: map[string][]string{\n" +
+		"{{range .Renamed}}" +
+		"				\"{{.JsonName}}\": []string{ {{- range $i, $was := .Was}}{{if $i}}, {{end}}\"{{$was}}\"{{end -}} },\n" +
+		"{{end}}" +
+		"			},\n" +
+		"{{end}}" +
+		"{{if .Stamps}}" +
+		"			stamps: []{{$.Prefix}}StampMetadata{\n" +
+		"{{range .Stamps}}" +
+		"				{domainName: \"{{.DomainName}}\", created: {{.Created}}},\n" +
+		"{{end}}" +
+		"			},\n" +
+		"{{end}}" +
+		"{{if .Version}}" +
+		"			version: \"{{.Version}}\",\n" +
+		"{{end}}" +
+		"{{if .Compress}}" +
+		"			compress: \"{{.Compress}}\",\n" +
+		"{{end}}" +
 		"		},{{end}}"
 )
@@ -3,9 +3,11 @@ package nodes
 import (
 	"cmp"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/hackborn/doc_drivers/enc"
+	"github.com/hackborn/doc_drivers/schema"
 )
 
 // MetadataDef is used by the  const template file.
@@ -14,14 +16,50 @@ type MetadataDef struct {
 	RootBucket    string
 	Buckets       []MetadataKeyDef
 	NewConvStruct string
+	Indexes       []MetadataIndexDef
+	Defaults      []MetadataDefaultDef
+	NotNull       []string
+	Formats       []MetadataFormatDef
+	Encrypted     []string
+	Compressed    []MetadataCompressDef
+	Flattened     []MetadataFlattenDef
+	Renamed       []MetadataRenameDef
+	Stamps        []MetadataStampDef
+	// Version is the name of the version field, if any.
+	Version string
+	// Compress is the compression algorithm of the
+	// whole record, if any.
+	Compress string
+
+	// columns describes every stored field, for the schema.
+	columns []schema.Column
+}
+
+// schemaTable answers the public description of this metadata.
+// The buckets form a single key, so they must be sorted first.
+func (m MetadataDef) schemaTable() schema.Table {
+	t := schema.Table{Struct: m.DomainName, Name: m.RootBucket, Columns: m.columns, Compress: m.Compress}
+	key := schema.KeyGroup{}
+	for _, b := range m.Buckets {
+		key.Columns = append(key.Columns, b.BoltName)
+	}
+	t.Keys = append(t.Keys, key)
+	for _, idx := range m.Indexes {
+		if idx.Constraint {
+			t.Uniques = append(t.Uniques, schema.KeyGroup{Name: idx.Name, Columns: idx.boltNames()})
+			continue
+		}
+		t.Indexes = append(t.Indexes, schema.Index{Name: idx.Name, Columns: idx.boltNames(), Unique: idx.Unique})
+	}
+	return t
 }
 
 func (m MetadataDef) Validate() error {
 	if m.RootBucket == "" {
-		return fmt.Errorf("Metadata for \"%v\" must have a root bucket", m.DomainName)
+		return enc.NewStructError(m.DomainName, fmt.Errorf("Metadata must have a root bucket"))
 	}
 	if len(m.Buckets) < 1 {
-		return fmt.Errorf("Metadata for \"%v\" must have at least one key tag", m.DomainName)
+		return enc.NewStructError(m.DomainName, fmt.Errorf("Metadata must have at least one key tag"))
 	}
 	// Can only have 1 autoinc key
 	autoincCount := 0
@@ -29,7 +67,7 @@ func (m MetadataDef) Validate() error {
 		if key.IsAutoInc() {
 			autoincCount++
 			if autoincCount > 1 {
-				return fmt.Errorf("Metadata for \"%v\" must not have more than one autoinc tag", m.DomainName)
+				return enc.NewStructError(m.DomainName, fmt.Errorf("Metadata must not have more than one autoinc tag"))
 			}
 		}
 	}
@@ -101,6 +139,99 @@ func compareKeys(a, b *metadataKeyInfo) int {
 	return strings.Compare(a.group, b.group)
 }
 
+// MetadataDefaultDef describes the default value of a field.
+type MetadataDefaultDef struct {
+	DomainName string
+	// Value is the default as a Go literal.
+	Value string
+}
+
+func newMetadataDefaultDef(domainName string, v any) MetadataDefaultDef {
+	if s, ok := v.(string); ok {
+		return MetadataDefaultDef{DomainName: domainName, Value: strconv.Quote(s)}
+	}
+	return MetadataDefaultDef{DomainName: domainName, Value: fmt.Sprint(v)}
+}
+
+// MetadataFormatDef describes a field that is stored
+// with a format other than json.
+type MetadataFormatDef struct {
+	DomainName string
+	Format     string
+}
+
+// MetadataCompressDef describes a compressed field.
+type MetadataCompressDef struct {
+	DomainName string
+	Compress   string
+}
+
+// MetadataFlattenDef describes a field of a flattened
+// struct, which is stored under its own name.
+type MetadataFlattenDef struct {
+	DomainName string
+	JsonName   string
+}
+
+// MetadataRenameDef describes a field that was stored
+// under other names before it was renamed.
+type MetadataRenameDef struct {
+	JsonName string
+	Was      []string
+}
+
+// MetadataStampDef describes a created or updated timestamp.
+type MetadataStampDef struct {
+	DomainName string
+	Created    bool
+}
+
+// MetadataIndexDef describes a secondary index. Unique
+// constraints are stored as unique indexes, flagged as
+// constraints to keep them apart from the indexes.
+type MetadataIndexDef struct {
+	Name       string
+	Unique     bool
+	Constraint bool
+	Fields     []MetadataIndexFieldDef
+}
+
+type MetadataIndexFieldDef struct {
+	DomainName string
+	BoltName   string
+	index      int
+}
+
+// DomainNames answers the field names as a Go list of strings.
+func (d MetadataIndexDef) DomainNames() string {
+	return quoteList(d.Fields, func(f MetadataIndexFieldDef) string {
+		return f.DomainName
+	})
+}
+
+// BoltNames answers the bolt names as a Go list of strings.
+func (d MetadataIndexDef) BoltNames() string {
+	return quoteList(d.Fields, func(f MetadataIndexFieldDef) string {
+		return f.BoltName
+	})
+}
+
+func (d MetadataIndexDef) boltNames() []string {
+	names := make([]string, 0, len(d.Fields))
+	for _, f := range d.Fields {
+		names = append(names, f.BoltName)
+	}
+	return names
+}
+
+func quoteList(fields []MetadataIndexFieldDef, fn func(MetadataIndexFieldDef) string) string {
+	quoted := make([]string, 0, len(fields))
+	for _, f := range fields {
+		quoted = append(quoted, strconv.Quote(fn(f)))
+	}
+	return strings.Join(quoted, ", ")
+}
+
 // JsonDef is used by the json template file.
 type JsonDef struct {
 	Name   string
@@ -0,0 +1,303 @@
+package bboltrefdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hackborn/doc"
+	"github.com/hackborn/onefunc/jacl"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ---------------------------------------------------------
+// TEST-NOT-NULL
+func TestNotNull(t *testing.T) {
+	_refMetadatas["notNullItem"] = &_refMetadata{
+		rootBucket: "notnull",
+		buckets: []_refKeyMetadata{
+			{domainName: "Id", boltName: "Id", ft: stringType, leaf: true},
+		},
+		newConvStruct: func() any { return &notNullItem{} },
+		notNull:       []string{"Name", "Tags"},
+	}
+	defer delete(_refMetadatas, "notNullItem")
+	doc.Register("test/notnull", NewDriver("bbolt"))
+	db, err := doc.Open("test/notnull", filepath.Join(t.TempDir(), "db.bbolt"))
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	defer db.Close()
+
+	f := func(item notNullItem, wantErr string) {
+		t.Helper()
+
+		_, err := doc.Set(db, doc.SetRequest[notNullItem]{Item: item})
+		switch {
+		case wantErr == "" && err != nil:
+			t.Fatalf("Has err %v", err)
+		case wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)):
+			t.Fatalf("Has err %v but wants %v", err, wantErr)
+		}
+	}
+	f(notNullItem{Id: "a", Tags: []string{}}, "")
+	f(notNullItem{Id: "b", Name: "b", Tags: []string{"x"}}, "")
+	f(notNullItem{Id: "c"}, `Field "Tags" is notnull but has no value`)
+}
+
+// ---------------------------------------------------------
+// TEST-COMPRESS
+func TestCompress(t *testing.T) {
+	_refMetadatas["compressItem"] = &_refMetadata{
+		rootBucket: "compress",
+		buckets: []_refKeyMetadata{
+			{domainName: "Id", boltName: "Id", ft: stringType, leaf: true},
+		},
+		newConvStruct: func() any { return &compressItem{} },
+		formats:       map[string]string{"Body": "json", "Tags": "gob"},
+		compressed:    map[string]string{"Body": "gzip", "Tags": "flate"},
+		compress:      "gzip",
+	}
+	defer delete(_refMetadatas, "compressItem")
+	doc.Register("test/compress", NewDriver("bbolt"))
+	path := filepath.Join(t.TempDir(), "db.bbolt")
+	db, err := doc.Open("test/compress", path)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	want := compressItem{Id: "a", Body: strings.Repeat("body ", 20), Tags: []string{"x", "y"}}
+	_, err = doc.Set(db, doc.SetRequest[compressItem]{Item: want})
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	req := doc.GetRequest{}
+	req.Condition, err = db.Expr("Id = a", nil).Compile()
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	resp, err := doc.Get[compressItem](db, req)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Body != want.Body || !slices.Equal(resp.Results[0].Tags, want.Tags) {
+		t.Fatalf("Has %v but wants %v", resp.Results, want)
+	}
+	db.Close()
+
+	// The stored record is gzipped.
+	bdb, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	defer bdb.Close()
+	err = bdb.View(func(tx *bolt.Tx) error {
+		_, v := tx.Bucket([]byte("compress")).Cursor().First()
+		if !bytes.HasPrefix(v, []byte{0x1f, 0x8b}) {
+			t.Fatalf("Has stored record %q but wants gzip", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-STORED-NAMES
+func TestStoredNames(t *testing.T) {
+	_refMetadatas["namedItem"] = &_refMetadata{
+		rootBucket: "named",
+		buckets: []_refKeyMetadata{
+			{domainName: "Id", boltName: "id", ft: stringType, leaf: true},
+		},
+		newConvStruct: func() any { return &namedJson{} },
+	}
+	defer delete(_refMetadatas, "namedItem")
+	doc.Register("test/named", NewDriver("bbolt"))
+	path := filepath.Join(t.TempDir(), "db.bbolt")
+	db, err := doc.Open("test/named", path)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	want := namedItem{Id: "a", FoundedYear: 1998, Name: "b"}
+	_, err = doc.Set(db, doc.SetRequest[namedItem]{Item: want})
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	req := doc.GetRequest{}
+	req.Condition, err = db.Expr("id = a", nil).Compile()
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	resp, err := doc.Get[namedItem](db, req)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	if len(resp.Results) != 1 || *resp.Results[0] != want {
+		t.Fatalf("Has %v but wants %v", resp.Results, want)
+	}
+	db.Close()
+
+	// The record is stored under the names of the json struct.
+	stored := readStored(t, path, "named")
+	if err := jacl.Run(stored, `founded_year=1998`, `name=b`); err != nil {
+		t.Fatalf("Has stored record %v (%v)", stored, err)
+	}
+	for _, name := range []string{"FoundedYear", "Name"} {
+		if _, ok := stored[name]; ok {
+			t.Fatalf("Has stored record %v with encoding/json name %v", stored, name)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-RENAMED
+func TestRenamed(t *testing.T) {
+	f := func(conv func() any, renamed map[string][]string) {
+		t.Helper()
+
+		_refMetadatas["renamedItem"] = &_refMetadata{
+			rootBucket: "renamed",
+			buckets: []_refKeyMetadata{
+				{domainName: "Id", boltName: "id", ft: stringType, leaf: true},
+			},
+			newConvStruct: conv,
+			renamed:       renamed,
+		}
+	}
+	defer delete(_refMetadatas, "renamedItem")
+	doc.Register("test/renamed", NewDriver("bbolt"))
+	path := filepath.Join(t.TempDir(), "db.bbolt")
+
+	// Write the record under the old name.
+	f(func() any { return &renamedOldJson{} }, nil)
+	db, err := doc.Open("test/renamed", path)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	_, err = doc.Set(db, doc.SetRequest[renamedItem]{Item: renamedItem{Id: "a", Body: "hello"}})
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	db.Close()
+	if stored := readStored(t, path, "renamed"); stored["text"] != "hello" {
+		t.Fatalf("Has stored record %v but wants text=hello", stored)
+	}
+
+	// Read it after the rename.
+	f(func() any { return &renamedJson{} }, map[string][]string{"body": {"content", "text"}})
+	db, err = doc.Open("test/renamed", path)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	defer db.Close()
+	req := doc.GetRequest{}
+	req.Condition, err = db.Expr("id = a", nil).Compile()
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	resp, err := doc.Get[renamedItem](db, req)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Body != "hello" {
+		t.Fatalf("Has %v but wants body hello", resp.Results)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-VERSION
+func TestVersion(t *testing.T) {
+	_refMetadatas["versionItem"] = &_refMetadata{
+		rootBucket: "version",
+		buckets: []_refKeyMetadata{
+			{domainName: "Id", boltName: "Id", ft: stringType, leaf: true},
+		},
+		newConvStruct: func() any { return &versionItem{} },
+		version:       "Version",
+	}
+	defer delete(_refMetadatas, "versionItem")
+	doc.Register("test/version", NewDriver("bbolt"))
+	db, err := doc.Open("test/version", filepath.Join(t.TempDir(), "db.bbolt"))
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	defer db.Close()
+
+	// Each response can be set again.
+	item := versionItem{Id: "a"}
+	for want := uint64(1); want <= 3; want++ {
+		resp, err := doc.Set(db, doc.SetRequest[versionItem]{Item: item})
+		if err != nil {
+			t.Fatalf("Has err %v", err)
+		}
+		if resp.Item == nil || resp.Item.Version != want {
+			t.Fatalf("Has response %v but wants version %v", resp.Item, want)
+		}
+		item = *resp.Item
+	}
+}
+
+// readStored answers the first record in the root bucket.
+func readStored(t *testing.T, path, rootBucket string) map[string]any {
+	t.Helper()
+
+	bdb, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	defer bdb.Close()
+	stored := make(map[string]any)
+	err = bdb.View(func(tx *bolt.Tx) error {
+		_, v := tx.Bucket([]byte(rootBucket)).Cursor().First()
+		return json.Unmarshal(v, &stored)
+	})
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	return stored
+}
+
+type namedItem struct {
+	Id          string
+	FoundedYear int
+	Name        string
+}
+
+type namedJson struct {
+	FoundedYear int    `json:"founded_year"`
+	Name        string `json:"name"`
+}
+
+type renamedItem struct {
+	Id   string
+	Body string
+}
+
+type renamedOldJson struct {
+	Body string `json:"text"`
+}
+
+type renamedJson struct {
+	Body string `json:"body"`
+}
+
+type versionItem struct {
+	Id      string
+	Version uint64
+}
+
+type compressItem struct {
+	Id   string
+	Body string
+	Tags []string
+}
+
+type notNullItem struct {
+	Id   string
+	Name string
+	Tags []string
+}
@@ -1,9 +0,0 @@
-package bboltrefdriver
-
-import (
-	"github.com/hackborn/doc"
-)
-
-func NewDriver(name string) doc.Driver {
-	return &_refDriver{}
-}
@@ -0,0 +1,54 @@
+package bboltrefdriver
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// _refCompress compresses the data with the
+// algorithm, either gzip or flate.
+func _refCompress(algorithm string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch algorithm {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "flate":
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		w = fw
+	default:
+		return nil, fmt.Errorf("Unknown compression \"%v\"", algorithm)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// _refDecompress decompresses data compressed by _refCompress.
+func _refDecompress(algorithm string, data []byte) ([]byte, error) {
+	var r io.ReadCloser
+	switch algorithm {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		r = gr
+	case "flate":
+		r = flate.NewReader(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("Unknown compression \"%v\"", algorithm)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -37,6 +37,48 @@ var (
 				{domainName: "FoundedYear", boltName: "fy", ft: stringType, leaf: false, flags: 0},
 			},
 			newConvStruct: func() any { return &_refJsonCompany{} },
+			indexes: []_refIndexMetadata{
+				{name: "ticker", unique: true, constraint: true, domainNames: []string{"Ticker"}, boltNames: []string{"ticker"}},
+			},
+			formats: map[string]string{
+				"Aliases": "gob",
+			},
+			stamps: []_refStampMetadata{
+				{domainName: "Created", created: true},
+				{domainName: "Updated", created: false},
+			},
+		},
+		`Contact`: {
+			rootBucket: "contact",
+			buckets: []_refKeyMetadata{
+				{domainName: "Record.Id", boltName: "id", ft: stringType, leaf: true, flags: 0},
+			},
+			newConvStruct: func() any { return &_refJsonContact{} },
+			indexes: []_refIndexMetadata{
+				{name: "home_city", unique: false, constraint: false, domainNames: []string{"Home.City"}, boltNames: []string{"home_city"}},
+			},
+			formats: map[string]string{
+				"Phone": "json",
+			},
+			encrypted: map[string]bool{
+				"Phone": true,
+			},
+			flattened: map[string]string{
+				"Record.Id":   "id",
+				"Home.Street": "home_street",
+				"Home.City":   "home_city",
+			},
+		},
+		`Document`: {
+			rootBucket: "document",
+			buckets: []_refKeyMetadata{
+				{domainName: "Id", boltName: "id", ft: stringType, leaf: true, flags: 0},
+			},
+			newConvStruct: func() any { return &_refJsonDocument{} },
+			renamed: map[string][]string{
+				"body": []string{"text"},
+			},
+			version: "Version",
 		},
 		`Events`: {
 			rootBucket: "events",
@@ -61,6 +103,13 @@ var (
 				{domainName: "Form", boltName: "form", ft: stringType, leaf: false, flags: 0},
 			},
 			newConvStruct: func() any { return &_refJsonFiling{} },
+			indexes: []_refIndexMetadata{
+				{name: "fy", unique: false, constraint: false, domainNames: []string{"FiscalYear"}, boltNames: []string{"fy"}},
+			},
+			defaults: map[string]any{
+				"Units": "usd",
+			},
+			notNull: []string{"Units"},
 		},
 		`UiSetting`: {
 			rootBucket: "settings",
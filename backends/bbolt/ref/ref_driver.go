@@ -1,11 +1,14 @@
 package bboltrefdriver
 
 import (
+	"bytes"
 	"cmp"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hackborn/doc"
+	"github.com/hackborn/doc_drivers/driverkit"
 	"github.com/hackborn/onefunc/errors"
 	"github.com/hackborn/onefunc/reflect"
 	bolt "go.etcd.io/bbolt"
@@ -14,6 +17,8 @@ import (
 type _refDriver struct {
 	db     *bolt.DB
 	format doc.Format
+	// keys seal the encrypted fields, and can be nil.
+	keys driverkit.KeyProvider
 }
 
 func (d *_refDriver) Private(a any) error {
@@ -36,7 +41,7 @@ func (d *_refDriver) Open(dataSourceName string) (doc.Driver, error) {
 	}
 
 	f := doc.FormatWithDefaults(_refNewFormat())
-	return &_refDriver{db: db, format: f}, nil
+	return &_refDriver{db: db, format: f, keys: d.keys}, nil
 }
 
 func (d *_refDriver) Close() error {
@@ -61,7 +66,7 @@ func (d *_refDriver) Set(req doc.SetRequestAny, a doc.Allocator) (*doc.Optional,
 	err = d.db.Update(func(tx *bolt.Tx) error {
 		rootB, lastErr := tx.CreateBucketIfNotExists([]byte(data.p.rootBucket))
 		b := rootB
-		for _, node := range data.p.nodes {
+		for i, node := range data.p.nodes {
 			if lastErr != nil {
 				return lastErr
 			}
@@ -79,7 +84,8 @@ func (d *_refDriver) Set(req doc.SetRequestAny, a doc.Allocator) (*doc.Optional,
 				if err != nil {
 					return err
 				}
-				return b.Put(_refItob(id), data.value)
+				// Autoincs are always the leaf, so this is the key.
+				data.p.nodes[i].value = _refItob(id)
 			}
 		}
 		if lastErr != nil {
@@ -92,14 +98,58 @@ func (d *_refDriver) Set(req doc.SetRequestAny, a doc.Allocator) (*doc.Optional,
 		if err != nil {
 			return err
 		}
-		err = b.Put(key, data.value)
-		return err
+		if data.meta.version != "" {
+			data.value, err = data.meta.setVersion(data.value, b.Get(key), req.ItemAny())
+			if err != nil {
+				return err
+			}
+		}
+		if len(data.meta.stamps) > 0 {
+			var prev []byte
+			// New items always get a new created stamp.
+			if req.GetFilter().Rule != doc.RuleCreateItem {
+				prev = b.Get(key)
+			}
+			data.value, err = data.meta.stamp(data.value, prev, req.ItemAny(), time.Now().UTC())
+			if err != nil {
+				return err
+			}
+		}
+		if len(data.meta.indexes) > 0 {
+			err = d.setIndexes(tx, b, key, data, req.ItemAny())
+			if err != nil {
+				return err
+			}
+		}
+		value, err := data.meta.encodeStored(data.value)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, value)
 		//		return b.Put(key, data.value)
 	})
+	if err == nil && data.meta.version != "" {
+		// The response has the stored version, so it can be set again.
+		err = data.meta.nextVersion(a.New(), req.ItemAny())
+	}
 
 	return nil, err
 }
 
+// setIndexes updates the indexes for the item that
+// is about to be stored at key in b.
+func (d *_refDriver) setIndexes(tx *bolt.Tx, b *bolt.Bucket, key boltKey, data setData, item any) error {
+	entry, err := encodeEntry(data.p)
+	if err != nil {
+		return err
+	}
+	prev, err := readPrev(data.meta, d.keys, b, key, item)
+	if err != nil {
+		return err
+	}
+	return putIndexes(tx, data.meta, entry, item, prev)
+}
+
 type setData struct {
 	meta  *_refMetadata
 	p     *path
@@ -114,10 +164,14 @@ func (d *_refDriver) prepareSet(req doc.SetRequestAny, a doc.Allocator) (setData
 		return ps, fmt.Errorf("missing metadata for \"%v\"", tn)
 	}
 	ps.p = newPath(meta.rootBucket, meta.buckets)
-	reflect.Get(req.ItemAny(), ps.p)
+	_refGetFields(req.ItemAny(), meta.DomainKeys(), ps.p)
+
+	if err := meta.checkNotNull(req.ItemAny()); err != nil {
+		return ps, err
+	}
 
 	// Marshal the data.
-	dbitem, err := meta.toDb(req.ItemAny())
+	dbitem, err := meta.toDb(req.ItemAny(), d.keys)
 	if err != nil {
 		return ps, err
 	}
@@ -136,7 +190,10 @@ func (d *_refDriver) Get(req doc.GetRequest, a doc.Allocator) (*doc.Optional, er
 		return nil, err
 	}
 	err = d.db.View(func(tx *bolt.Tx) error {
-		it, err := newGetIterator(get.meta, tx, get.p, a)
+		if get.index != nil {
+			return d.getIndexed(tx, get, a)
+		}
+		it, err := newGetIterator(get.meta, d.keys, tx, get.p, a)
 		if err != nil {
 			return err
 		}
@@ -152,6 +209,10 @@ func (d *_refDriver) Get(req doc.GetRequest, a doc.Allocator) (*doc.Optional, er
 type getData struct {
 	meta *_refMetadata
 	p    *path
+	// index is set when the condition is answered from an
+	// index, in which case indexKey is the value bucket.
+	index    *_refIndexMetadata
+	indexKey []byte
 }
 
 func (d *_refDriver) prepareGet(req doc.GetRequest, a doc.Allocator) (getData, error) {
@@ -162,10 +223,41 @@ func (d *_refDriver) prepareGet(req doc.GetRequest, a doc.Allocator) (getData, e
 		return get, fmt.Errorf("missing metadata for \"%v\"", tn)
 	}
 	get.p = newPath(meta.rootBucket, meta.buckets)
-	err := extractExpr(req.Condition, get.p)
+	cond := &getCondition{path: get.p, assigned: make(map[string]any)}
+	err := extractExpr(req.Condition, cond)
+	get.index, get.indexKey = meta.findIndex(cond)
 	return get, err
 }
 
+// getIndexed answers the items in the index value bucket that
+// also match any keys in the condition.
+func (d *_refDriver) getIndexed(tx *bolt.Tx, get getData, a doc.Allocator) error {
+	b := tx.Bucket(get.index.bucketName(get.meta.rootBucket))
+	if b == nil {
+		return nil
+	}
+	vb := b.Bucket(get.indexKey)
+	if vb == nil {
+		return nil
+	}
+	c := vb.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		p := newPath(get.meta.rootBucket, get.meta.buckets)
+		err := decodeEntry(k, p)
+		if err != nil {
+			return err
+		}
+		if !p.matches(get.p) {
+			continue
+		}
+		err = getItem(tx, get.meta, d.keys, p, a)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *_refDriver) Delete(req doc.DeleteRequestAny, a doc.Allocator) (*doc.Optional, error) {
 	del, err := d.prepareDelete(req, a)
 	if err != nil {
@@ -178,7 +270,7 @@ func (d *_refDriver) Delete(req doc.DeleteRequestAny, a doc.Allocator) (*doc.Opt
 		}
 		for i, node := range del.p.nodes {
 			if node.leaf {
-				return b.Delete([]byte(del.key))
+				return d.deleteItem(tx, b, del, req.ItemAny())
 			}
 			b = b.Bucket(node.value)
 			if b == nil {
@@ -186,7 +278,7 @@ func (d *_refDriver) Delete(req doc.DeleteRequestAny, a doc.Allocator) (*doc.Opt
 			}
 
 			if i >= len(del.p.nodes)-1 {
-				return b.Delete([]byte(del.key))
+				return d.deleteItem(tx, b, del, req.ItemAny())
 			}
 		}
 		return fmt.Errorf("Fell out of loop")
@@ -194,6 +286,28 @@ func (d *_refDriver) Delete(req doc.DeleteRequestAny, a doc.Allocator) (*doc.Opt
 	return nil, err
 }
 
+// deleteItem deletes the item stored at the key in b, and
+// removes it from the indexes.
+func (d *_refDriver) deleteItem(tx *bolt.Tx, b *bolt.Bucket, del deleteData, item any) error {
+	if len(del.meta.indexes) > 0 {
+		prev, err := readPrev(del.meta, d.keys, b, del.key, item)
+		if err != nil {
+			return err
+		}
+		if prev != nil {
+			entry, err := encodeEntry(del.p)
+			if err != nil {
+				return err
+			}
+			err = deleteIndexes(tx, del.meta, entry, prev)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return b.Delete([]byte(del.key))
+}
+
 type deleteData struct {
 	meta *_refMetadata
 	p    *path
@@ -212,7 +326,7 @@ func (d *_refDriver) prepareDelete(req doc.DeleteRequestAny, a doc.Allocator) (d
 		return del, fmt.Errorf("missing metadata for \"%v\"", tn)
 	}
 	del.p = newPath(meta.rootBucket, meta.buckets)
-	reflect.Get(item, del.p)
+	_refGetFields(item, meta.DomainKeys(), del.p)
 	k, err := del.p.makeKey()
 	if err != nil {
 		return del, err
@@ -291,6 +405,7 @@ type getIterator interface {
 }
 
 func newGetIterator(meta *_refMetadata,
+	keys driverkit.KeyProvider,
 	tx *bolt.Tx,
 	p *path,
 	a doc.Allocator) (getIterator, error) {
@@ -303,9 +418,10 @@ func newGetIterator(meta *_refMetadata,
 	//	fmt.Println("GET PATH", *p)
 	steps := make([]wildcardIteratorStep, 0, len(p.nodes))
 	steps = append(steps, wildcardIteratorStep{})
-	req := reflect.SetRequest{FieldNames: meta.DomainKeys(),
-		NewValues: make([]any, len(meta.DomainKeys()))}
+	req := _refSetFields(reflect.SetRequest{FieldNames: meta.DomainKeys(),
+		NewValues: make([]any, len(meta.DomainKeys()))})
 	return &wildcardIterator{meta: meta,
+		keys:  keys,
 		a:     a,
 		tx:    tx,
 		b:     b,
@@ -318,6 +434,7 @@ func newGetIterator(meta *_refMetadata,
 // case buckets are iterated.
 type wildcardIterator struct {
 	meta *_refMetadata
+	keys driverkit.KeyProvider
 	a    doc.Allocator
 	tx   *bolt.Tx
 	// root bucket
@@ -347,7 +464,7 @@ func (w *wildcardIterator) Next() any {
 // domainItem converst the key and value into a domain item.
 func (w *wildcardIterator) domainItem(k, v []byte) any {
 	//	fmt.Println("domain item", k, string(v), "path len", len(w.steps))
-	item, err := w.meta.fromDb(w.a.New(), v)
+	item, err := w.meta.fromDb(w.a.New(), v, w.keys)
 	//	fmt.Println("key", string(k), "value", string(v))
 	w.err = cmp.Or(w.err, err)
 	// Set the keys. The values should be in the steps.
@@ -695,6 +812,17 @@ func (p *path) BinaryAssignment(lhs string, rhs any) error {
 	return nil
 }
 
+// matches answers false if any value in the condition
+// path differs from my value.
+func (p *path) matches(cond *path) bool {
+	for i, n := range cond.nodes {
+		if n.value != nil && !bytes.Equal(n.value, p.nodes[i].value) {
+			return false
+		}
+	}
+	return true
+}
+
 // makeKey returns a value to be used as the key in the database.
 func (p *path) makeKey() (boltKey, error) {
 	// Validate
@@ -724,6 +852,48 @@ func (p *path) makeKey() (boltKey, error) {
 // ---------------------------------------------------------
 // MISC
 
+// getItem allocates the single item addressed by the path,
+// which must have a value for every node. Missing items
+// are ignored.
+func getItem(tx *bolt.Tx, meta *_refMetadata, keys driverkit.KeyProvider, p *path, a doc.Allocator) error {
+	b := tx.Bucket([]byte(meta.rootBucket))
+	for _, node := range p.nodes {
+		if b == nil {
+			return nil
+		}
+		if node.pt == bucketType {
+			b = b.Bucket(node.value)
+		}
+	}
+	if b == nil {
+		return nil
+	}
+	key, err := p.makeKey()
+	if err != nil {
+		return err
+	}
+	v := b.Get(key)
+	if v == nil {
+		return nil
+	}
+	item, err := meta.fromDb(a.New(), v, keys)
+	if err != nil {
+		return err
+	}
+	// Set the keys from the path, the same as the iterator.
+	req := _refSetFields(reflect.SetRequest{FieldNames: meta.DomainKeys(),
+		NewValues: make([]any, len(p.nodes))})
+	for i, node := range p.nodes {
+		if node.ft == stringType {
+			req.NewValues[i] = string(node.value)
+		} else if node.ft == uint64Type {
+			req.NewValues[i] = _refBtoi(node.value)
+		}
+	}
+	reflect.Set(req, item)
+	return nil
+}
+
 func getAutoIncKey(flags keyFlags, root, b *bolt.Bucket) (uint64, error) {
 	if flags&FlagAutoIncLocal != 0 {
 		return b.NextSequence()
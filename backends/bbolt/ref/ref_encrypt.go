@@ -0,0 +1,68 @@
+package bboltrefdriver
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/hackborn/doc_drivers/driverkit"
+)
+
+// _refSeal encrypts the data with AES-GCM, answering
+// the nonce followed by the sealed data. The data is bound
+// to the table and column, so it can't be opened from
+// any other column.
+func _refSeal(keys driverkit.KeyProvider, table, column string, data []byte) ([]byte, error) {
+	aead, err := _refNewAead(keys, table)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, _refAad(table, column)), nil
+}
+
+// _refOpen decrypts data sealed by _refSeal. Data sealed before
+// the column was renamed is opened with the previous names.
+func _refOpen(keys driverkit.KeyProvider, table, column string, was []string, data []byte) ([]byte, error) {
+	aead, err := _refNewAead(keys, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted value in %v is too short", table)
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	opened, err := aead.Open(nil, nonce, sealed, _refAad(table, column))
+	for _, name := range was {
+		if err == nil {
+			break
+		}
+		opened, err = aead.Open(nil, nonce, sealed, _refAad(table, name))
+	}
+	return opened, err
+}
+
+// _refAad answers the additional data that binds
+// a sealed value to its table and column.
+func _refAad(table, column string) []byte {
+	return []byte(table + "\x00" + column)
+}
+
+func _refNewAead(keys driverkit.KeyProvider, table string) (cipher.AEAD, error) {
+	if keys == nil {
+		return nil, driverkit.ErrNoKeyProvider
+	}
+	key, err := keys.Key(table)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,232 @@
+package bboltrefdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/hackborn/doc_drivers/driverkit"
+)
+
+// _refIndexMetadata describes a secondary index. Index entries live
+// in their own top-level bucket, with a nested bucket for each index
+// value that holds the key paths of the matching items.
+type _refIndexMetadata struct {
+	name   string
+	unique bool
+	// constraint is true for unique constraints, which
+	// are kept in their own buckets.
+	constraint bool
+	// domainNames are the struct fields in the index, in order.
+	domainNames []string
+	// boltNames are the names used in expressions.
+	boltNames []string
+}
+
+// bucketName answers the name of the top-level bucket for the index.
+func (m _refIndexMetadata) bucketName(rootBucket string) []byte {
+	if m.constraint {
+		return []byte(_refUniqueBucketPrefix + rootBucket + "/" + m.name)
+	}
+	return []byte(_refIndexBucketPrefix + rootBucket + "/" + m.name)
+}
+
+// valueKey answers the nested bucket name for the index values.
+// Expressions don't know the type of a value, so every value
+// is compared as a string.
+func (m _refIndexMetadata) valueKey(values []any) []byte {
+	var key []byte
+	for _, v := range values {
+		key = fmt.Appendf(key, "%v\x00", v)
+	}
+	return key
+}
+
+// itemValueKey answers the nested bucket name for the domain item.
+func (m _refIndexMetadata) itemValueKey(item any) []byte {
+	h := &indexValues{names: m.domainNames, values: make([]any, len(m.domainNames))}
+	_refGetFields(item, m.domainNames, h)
+	return m.valueKey(h.values)
+}
+
+// exprValueKey answers the nested bucket name for the values
+// assigned in an expression, or false if any are missing.
+func (m _refIndexMetadata) exprValueKey(assigned map[string]any) ([]byte, bool) {
+	values := make([]any, 0, len(m.boltNames))
+	for _, name := range m.boltNames {
+		v, ok := assigned[name]
+		if !ok {
+			return nil, false
+		}
+		values = append(values, v)
+	}
+	return m.valueKey(values), true
+}
+
+// indexValues is used by the reflection system to extract
+// the index values from a domain item.
+type indexValues struct {
+	names  []string
+	values []any
+}
+
+func (h *indexValues) Handle(name string, value any) (string, any) {
+	if i := slices.Index(h.names, name); i >= 0 {
+		h.values[i] = value
+	}
+	return name, value
+}
+
+// putIndexes adds the item to every index, removing the entries
+// for the previous version of the item, if any.
+func putIndexes(tx *bolt.Tx, meta *_refMetadata, entry []byte, item, prev any) error {
+	for _, idx := range meta.indexes {
+		b, err := tx.CreateBucketIfNotExists(idx.bucketName(meta.rootBucket))
+		if err != nil {
+			return err
+		}
+		key := idx.itemValueKey(item)
+		if prev != nil {
+			prevKey := idx.itemValueKey(prev)
+			if bytes.Equal(prevKey, key) {
+				continue
+			}
+			if err := deleteIndexEntry(b, prevKey, entry); err != nil {
+				return err
+			}
+		}
+		vb, err := b.CreateBucketIfNotExists(key)
+		if err != nil {
+			return err
+		}
+		if idx.unique {
+			if k, _ := vb.Cursor().First(); k != nil && !bytes.Equal(k, entry) {
+				return &driverkit.UniqueError{Table: meta.rootBucket, Columns: idx.boltNames}
+			}
+		}
+		if err := vb.Put(entry, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteIndexes removes the item from every index.
+func deleteIndexes(tx *bolt.Tx, meta *_refMetadata, entry []byte, item any) error {
+	for _, idx := range meta.indexes {
+		b := tx.Bucket(idx.bucketName(meta.rootBucket))
+		if b == nil {
+			continue
+		}
+		if err := deleteIndexEntry(b, idx.itemValueKey(item), entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteIndexEntry removes the entry from the value bucket,
+// and the value bucket once it's empty.
+func deleteIndexEntry(b *bolt.Bucket, key, entry []byte) error {
+	vb := b.Bucket(key)
+	if vb == nil {
+		return nil
+	}
+	if err := vb.Delete(entry); err != nil {
+		return err
+	}
+	if k, _ := vb.Cursor().First(); k == nil {
+		return b.DeleteBucket(key)
+	}
+	return nil
+}
+
+// encodeEntry answers the index entry for the path, which
+// must have a value for every node.
+func encodeEntry(p *path) ([]byte, error) {
+	values := make([][]byte, 0, len(p.nodes))
+	for _, n := range p.nodes {
+		if n.value == nil {
+			return nil, fmt.Errorf("Missing value for %v", n.domainName)
+		}
+		values = append(values, n.value)
+	}
+	return json.Marshal(values)
+}
+
+// decodeEntry sets the path node values from the index entry.
+func decodeEntry(entry []byte, p *path) error {
+	var values [][]byte
+	err := json.Unmarshal(entry, &values)
+	if err != nil {
+		return err
+	}
+	if len(values) != len(p.nodes) {
+		return fmt.Errorf("index entry has %v values but want %v", len(values), len(p.nodes))
+	}
+	for i, v := range values {
+		p.nodes[i].value = v
+	}
+	return nil
+}
+
+// readPrev answers the stored version of the item, or nil.
+func readPrev(meta *_refMetadata, keys driverkit.KeyProvider, b *bolt.Bucket, key boltKey, item any) (any, error) {
+	v := b.Get(key)
+	if v == nil {
+		return nil, nil
+	}
+	t := reflect.TypeOf(item)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return meta.fromDb(reflect.New(t).Interface(), v, keys)
+}
+
+// getCondition extracts the key values from an expression into
+// the path, and records every assignment for the indexes.
+type getCondition struct {
+	*path
+	assigned map[string]any
+}
+
+func (c *getCondition) BinaryAssignment(lhs string, rhs any) error {
+	c.assigned[lhs] = rhs
+	return c.path.BinaryAssignment(lhs, rhs)
+}
+
+// findIndex answers the index to use for the condition, and the
+// value bucket to read. Indexes are only used if the keys don't
+// address the items directly.
+func (m *_refMetadata) findIndex(c *getCondition) (*_refIndexMetadata, []byte) {
+	if c.complete() {
+		return nil, nil
+	}
+	for i, idx := range m.indexes {
+		if key, ok := idx.exprValueKey(c.assigned); ok {
+			return &m.indexes[i], key
+		}
+	}
+	return nil, nil
+}
+
+// complete answers true if every path node has a value.
+func (p *path) complete() bool {
+	for _, n := range p.nodes {
+		if n.value == nil {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	// Index buckets are top-level, so they are named to stay
+	// clear of the root buckets.
+	_refIndexBucketPrefix  = "_index/"
+	_refUniqueBucketPrefix = "_unique/"
+)
@@ -7,7 +7,23 @@ type _refJsonCollectionSetting struct {
 }
 
 type _refJsonCompany struct {
-	Value int64 `json:"val"`
+	Ticker  string   `json:"ticker"`
+	Value   int64    `json:"val"`
+	Aliases []string `json:"aliases"`
+	Created any      `json:"created"`
+	Updated int64    `json:"updated"`
+}
+
+type _refJsonContact struct {
+	Name       string `json:"name"`
+	HomeStreet string `json:"home_street"`
+	HomeCity   string `json:"home_city"`
+	Phone      string `json:"phone"`
+}
+
+type _refJsonDocument struct {
+	Body    string `json:"body"`
+	Version int64  `json:"version"`
 }
 
 type _refJsonEvents struct {
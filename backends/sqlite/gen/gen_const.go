@@ -10,24 +10,32 @@ const (
 	genTableVar       = "$TABLE$"
 	genValuesVar      = "$VALUES$"
 	genFieldValuesVar = "$FIELDVALUES$"
+	genAssignsVar     = "$ASSIGNS$"
+	genVersionVar     = "$VERSION$"
 
 	genAndKeyword      = "AND"
 	genAndKeywordWS    = " AND "
 	genEqualsKeyword   = "="
 	genEqualsKeywordWS = " = "
 
-	genQuoteSz = string(rune('\''))
+	// Placeholder for a bound value in a statement.
+	genPlaceholder = "?"
 
 	genSetSql = `INSERT INTO $TABLE$ ($FIELDS$) VALUES($VALUES$) ON CONFLICT($KEYS$) DO UPDATE SET $FIELDVALUES$;`
 	genDelSql = `DELETE FROM $TABLE$ WHERE ($KEYVALUES$);`
+
+	// Versioned tables only write when the stored version is
+	// one less than the new version. Missing versions are 0.
+	genSetVersionSql    = `INSERT INTO $TABLE$ ($FIELDS$) VALUES($VALUES$) ON CONFLICT($KEYS$) DO UPDATE SET $FIELDVALUES$ WHERE coalesce($TABLE$.$VERSION$, 0) = excluded.$VERSION$ - 1;`
+	genUpdateVersionSql = `UPDATE $TABLE$ SET $ASSIGNS$ WHERE ($KEYVALUES$) AND coalesce($VERSION$, 0) = ?;`
 )
 
 var (
 	genTableDefs = map[string]genSqlTableDef{
 		`CollectionSetting`: {
 			cols: []genSqlTableCol{
-				{`name`, `VARCHAR(255)`, ``, 0},
-				{`value`, `TEXT`, `json`, 0},
+				{`name`, `VARCHAR(255)`, ``, colFlagNotNull, "", nil},
+				{`value`, `TEXT`, `json`, 0, "", nil},
 			},
 			create: `DROP TABLE IF EXISTS gensettings;
 CREATE TABLE IF NOT EXISTS gensettings (
@@ -38,27 +46,69 @@ CREATE TABLE IF NOT EXISTS gensettings (
 `,
 		}, `Company`: {
 			cols: []genSqlTableCol{
-				{`id`, `VARCHAR(255)`, ``, 0},
-				{`name`, `VARCHAR(255)`, ``, 0},
-				{`val`, `INTEGER`, ``, 0},
-				{`fy`, `INTEGER`, ``, 0},
+				{`id`, `VARCHAR(255)`, ``, colFlagNotNull, "", nil},
+				{`name`, `VARCHAR(255)`, ``, 0, "", nil},
+				{`ticker`, `VARCHAR(255)`, ``, 0, "", nil},
+				{`val`, `INTEGER`, ``, 0, "", nil},
+				{`aliases`, `BLOB`, `gob`, 0, "", nil},
+				{`fy`, `INTEGER`, ``, 0, "", nil},
+				{`created`, `TEXT`, `json`, colFlagCreated, "", nil},
+				{`updated`, `INTEGER`, ``, colFlagUpdated, "", nil},
 			},
 			create: `DROP TABLE IF EXISTS gencompany;
 CREATE TABLE IF NOT EXISTS gencompany (
 	id VARCHAR(255) NOT NULL,
 	name VARCHAR(255),
+	ticker VARCHAR(255),
 	val INTEGER,
+	aliases BLOB,
 	fy INTEGER,
-	PRIMARY KEY (id)
+	created TEXT,
+	updated INTEGER,
+	PRIMARY KEY (id),
+	CONSTRAINT gencompany_ticker UNIQUE (ticker)
 );
 CREATE INDEX IF NOT EXISTS b ON gencompany (name);
 CREATE INDEX IF NOT EXISTS c ON gencompany (fy);
+`,
+		}, `Contact`: {
+			cols: []genSqlTableCol{
+				{`id`, `VARCHAR(255)`, ``, colFlagNotNull, "", nil},
+				{`name`, `VARCHAR(255)`, ``, 0, "", nil},
+				{`home_street`, `VARCHAR(255)`, ``, 0, "", nil},
+				{`home_city`, `VARCHAR(255)`, ``, 0, "", nil},
+				{`phone`, `BLOB`, `json`, colFlagEncrypt, "", nil},
+			},
+			create: `DROP TABLE IF EXISTS gencontact;
+CREATE TABLE IF NOT EXISTS gencontact (
+	id VARCHAR(255) NOT NULL,
+	name VARCHAR(255),
+	home_street VARCHAR(255),
+	home_city VARCHAR(255),
+	phone BLOB,
+	PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS gencontact_home_city ON gencontact (home_city);
+`,
+		}, `Document`: {
+			cols: []genSqlTableCol{
+				{`id`, `VARCHAR(255)`, ``, colFlagNotNull, "", nil},
+				{`body`, `VARCHAR(255)`, ``, 0, "", []string{`text`}},
+				{`version`, `INTEGER`, ``, colFlagVersion, "", nil},
+			},
+			create: `DROP TABLE IF EXISTS gendocument;
+CREATE TABLE IF NOT EXISTS gendocument (
+	id VARCHAR(255) NOT NULL,
+	body VARCHAR(255),
+	version INTEGER,
+	PRIMARY KEY (id)
+);
 `,
 		}, `Events`: {
 			cols: []genSqlTableCol{
-				{`time`, `INTEGER`, ``, colFlagAuto},
-				{`name`, `VARCHAR(255)`, ``, 0},
-				{`value`, `VARCHAR(255)`, ``, 0},
+				{`time`, `INTEGER`, ``, colFlagAuto | colFlagNotNull, "", nil},
+				{`name`, `VARCHAR(255)`, ``, 0, "", nil},
+				{`value`, `VARCHAR(255)`, ``, 0, "", nil},
 			},
 			create: `DROP TABLE IF EXISTS genevents;
 CREATE TABLE IF NOT EXISTS genevents (
@@ -67,15 +117,28 @@ CREATE TABLE IF NOT EXISTS genevents (
 	value VARCHAR(255),
 	PRIMARY KEY (time)
 );
+CREATE INDEX IF NOT EXISTS b ON genevents (name);
+`,
+		}, `FavouritesSetting`: {
+			cols: []genSqlTableCol{
+				{`name`, `VARCHAR(255)`, ``, colFlagNotNull, "", nil},
+				{`value`, `TEXT`, `json`, 0, "", nil},
+			},
+			create: `DROP TABLE IF EXISTS gensettings;
+CREATE TABLE IF NOT EXISTS gensettings (
+	name VARCHAR(255) NOT NULL,
+	value TEXT,
+	PRIMARY KEY (name)
+);
 `,
 		}, `Filing`: {
 			cols: []genSqlTableCol{
-				{`ticker`, `VARCHAR(255)`, ``, 0},
-				{`end`, `VARCHAR(255)`, ``, 0},
-				{`form`, `VARCHAR(255)`, ``, 0},
-				{`val`, `INTEGER`, ``, 0},
-				{`units`, `VARCHAR(255)`, ``, 0},
-				{`fy`, `INTEGER`, ``, 0},
+				{`ticker`, `VARCHAR(255)`, ``, colFlagNotNull, "", nil},
+				{`end`, `VARCHAR(255)`, ``, colFlagNotNull, "", nil},
+				{`form`, `VARCHAR(255)`, ``, colFlagNotNull, "", nil},
+				{`val`, `INTEGER`, ``, 0, "", nil},
+				{`units`, `VARCHAR(255)`, ``, colFlagNotNull, "'usd'", nil},
+				{`fy`, `INTEGER`, ``, 0, "", nil},
 			},
 			create: `DROP TABLE IF EXISTS genfiling;
 CREATE TABLE IF NOT EXISTS genfiling (
@@ -83,15 +146,16 @@ CREATE TABLE IF NOT EXISTS genfiling (
 	end VARCHAR(255) NOT NULL,
 	form VARCHAR(255) NOT NULL,
 	val INTEGER,
-	units VARCHAR(255),
+	units VARCHAR(255) NOT NULL DEFAULT 'usd',
 	fy INTEGER,
 	PRIMARY KEY (ticker,end,form)
 );
+CREATE INDEX IF NOT EXISTS genfiling_fy ON genfiling (fy);
 `,
 		}, `UiSetting`: {
 			cols: []genSqlTableCol{
-				{`name`, `VARCHAR(255)`, ``, 0},
-				{`value`, `TEXT`, `json`, 0},
+				{`name`, `VARCHAR(255)`, ``, colFlagNotNull, "", nil},
+				{`value`, `TEXT`, `json`, 0, "", nil},
 			},
 			create: `DROP TABLE IF EXISTS gensettings;
 CREATE TABLE IF NOT EXISTS gensettings (
@@ -116,13 +180,9 @@ CREATE TABLE IF NOT EXISTS gensettings (
 			},
 		}, `Company`: {
 			table:  "gencompany",
-			tags:   []string{"id", "name", "val", "fy"},
-			fields: []string{"Id", "Name", "Value", "FoundedYear"},
+			tags:   []string{"id", "name", "ticker", "val", "aliases", "fy", "created", "updated"},
+			fields: []string{"Id", "Name", "Ticker", "Value", "Aliases", "FoundedYear", "Created", "Updated"},
 			keys: map[string]*genKeyMetadata{
-				"c": {
-					tags:   []string{"fy"},
-					fields: []string{"FoundedYear"},
-				},
 				"": {
 					tags:   []string{"id"},
 					fields: []string{"Id"},
@@ -131,6 +191,30 @@ CREATE TABLE IF NOT EXISTS gensettings (
 					tags:   []string{"name"},
 					fields: []string{"Name"},
 				},
+				"c": {
+					tags:   []string{"fy"},
+					fields: []string{"FoundedYear"},
+				},
+			},
+		}, `Contact`: {
+			table:  "gencontact",
+			tags:   []string{"id", "name", "home_street", "home_city", "phone"},
+			fields: []string{"Record.Id", "Name", "Home.Street", "Home.City", "Phone"},
+			keys: map[string]*genKeyMetadata{
+				"": {
+					tags:   []string{"id"},
+					fields: []string{"Record.Id"},
+				},
+			},
+		}, `Document`: {
+			table:  "gendocument",
+			tags:   []string{"id", "body", "version"},
+			fields: []string{"Id", "Body", "Version"},
+			keys: map[string]*genKeyMetadata{
+				"": {
+					tags:   []string{"id"},
+					fields: []string{"Id"},
+				},
 			},
 		}, `Events`: {
 			table:  "genevents",
@@ -141,6 +225,20 @@ CREATE TABLE IF NOT EXISTS gensettings (
 					tags:   []string{"time"},
 					fields: []string{"Time"},
 				},
+				"b": {
+					tags:   []string{"name"},
+					fields: []string{"Name"},
+				},
+			},
+		}, `FavouritesSetting`: {
+			table:  "gensettings",
+			tags:   []string{"name", "value"},
+			fields: []string{"Name", "Value"},
+			keys: map[string]*genKeyMetadata{
+				"": {
+					tags:   []string{"name"},
+					fields: []string{"Name"},
+				},
 			},
 		}, `Filing`: {
 			table:  "genfiling",
@@ -7,8 +7,10 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hackborn/doc"
+	"github.com/hackborn/doc_drivers/driverkit"
 	"github.com/hackborn/onefunc/errors"
 	oferrors "github.com/hackborn/onefunc/errors"
 	"github.com/hackborn/onefunc/reflect"
@@ -19,6 +21,8 @@ type genDriver struct {
 	db            *sql.DB
 	sqlDriverName string
 	format        doc.Format
+	// keys seal the encrypted fields, and can be nil.
+	keys driverkit.KeyProvider
 }
 
 func (d *genDriver) Open(dataSourceName string) (doc.Driver, error) {
@@ -30,7 +34,7 @@ func (d *genDriver) Open(dataSourceName string) (doc.Driver, error) {
 		return nil, eb.Err
 	}
 	f := doc.FormatWithDefaults(genNewFormat())
-	return &genDriver{db: db, format: f}, nil
+	return &genDriver{db: db, format: f, keys: d.keys}, nil
 }
 
 func (d *genDriver) Close() error {
@@ -47,34 +51,68 @@ func (d *genDriver) Format() doc.Format {
 }
 
 func (d *genDriver) Set(req doc.SetRequestAny, a doc.Allocator) (*doc.Optional, error) {
-	meta, keys, cols, err := d.prepareSet(a)
+	meta, keys, cols, err := d.prepareWrite(a)
 	if err != nil {
 		return nil, err
 	}
 
 	eb := &errors.FirstBlock{}
-	statement := genSetSql
-	handler := &fieldsAndValuesHandler{cols: cols, filter: req.GetFilter()}
-	ca1 := ofstrings.CompileArgs{Quote: "", Separator: ", ", Eb: eb}
-	ca2 := ofstrings.CompileArgs{Quote: genQuoteSz, Separator: ", ", Eb: eb}
-	reflect.Get(req.ItemAny(), reflect.NewChain(meta.FieldsToTags(), handler))
-	s := strings.ReplaceAll(statement, genFieldsVar, ofstrings.Compile(ca1, handler.fields...))
-	s = strings.ReplaceAll(s, genValuesVar, ofstrings.Compile(ca2, handler.values...))
-	s = strings.ReplaceAll(s, genFieldValuesVar, makeExcludedFieldValues(eb, handler.fields))
+	handler := &fieldsAndValuesHandler{cols: cols, filter: req.GetFilter(), now: time.Now().UTC(), keys: d.keys, table: meta.table}
+	ca := ofstrings.CompileArgs{Quote: "", Separator: ", ", Eb: eb}
+	genGetFields(req.ItemAny(), meta.FieldsToTags(), handler)
+	statement, args := genSetSql, handler.values
+	version := handler.version
+	if version != nil {
+		// Only version 0 can insert, every other
+		// version must update the stored row.
+		statement = genSetVersionSql
+		if version.value != 0 {
+			statement, args = genUpdateVersionSql, handler.updateArgs(keys.tags)
+			keyNames := make([]any, 0, len(keys.tags))
+			for _, tag := range keys.tags {
+				keyNames = append(keyNames, tag)
+			}
+			statement = strings.ReplaceAll(statement, genKeyValuesVar, makeKeyValues(eb, keyNames))
+		}
+	}
+	s := strings.ReplaceAll(statement, genFieldsVar, ofstrings.Compile(ca, handler.fields...))
+	s = strings.ReplaceAll(s, genValuesVar, makePlaceholders(eb, len(handler.values)))
+	s = strings.ReplaceAll(s, genFieldValuesVar, makeExcludedFieldValues(eb, handler.updates))
+	s = strings.ReplaceAll(s, genAssignsVar, makeAssignments(eb, handler.updates))
 	s = strings.ReplaceAll(s, genTableVar, meta.table)
-	s = strings.ReplaceAll(s, genKeysVar, ofstrings.CompileStrings(ca1, keys.tags...))
+	s = strings.ReplaceAll(s, genKeysVar, ofstrings.CompileStrings(ca, keys.tags...))
+	if version != nil {
+		s = strings.ReplaceAll(s, genVersionVar, version.name)
+	}
+	eb.AddError(handler.err)
 	if eb.Err != nil {
 		return nil, eb.Err
 	}
 
-	//	fmt.Println("EXEC", s)
-	if _, err := d.db.Exec(s); err != nil {
-		return nil, err
+	//	fmt.Println("EXEC", s, args)
+	result, err := d.db.Exec(s, args...)
+	if err != nil {
+		return nil, genUniqueError(err)
+	}
+	if version != nil {
+		// Nothing is written when the version doesn't match.
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n < 1 {
+			return nil, &driverkit.ConflictError{Table: meta.table, Version: version.value}
+		}
+		// The response has the stored version, so it can be set again.
+		field := meta.TagsToFields()[version.name]
+		if err := genNextVersion(a.New(), req.ItemAny(), field, version.value+1); err != nil {
+			return nil, err
+		}
 	}
 	return nil, nil
 }
 
-func (d *genDriver) prepareSet(a doc.Allocator) (*genMetadata, *genKeyMetadata, []genSqlTableCol, error) {
+func (d *genDriver) prepareWrite(a doc.Allocator) (*genMetadata, *genKeyMetadata, []genSqlTableCol, error) {
 	tn := a.TypeName()
 	meta, ok := genMetadatas[tn]
 	if !ok {
@@ -99,7 +137,7 @@ func (d *genDriver) Get(req doc.GetRequest, a doc.Allocator) (*doc.Optional, err
 	eb := &errors.FirstBlock{}
 	ca := ofstrings.CompileArgs{Quote: "", Separator: ", ", Eb: eb}
 	selectFields := ofstrings.CompileStrings(ca, tags...)
-	where, err := whereClause(req)
+	where, args, err := whereClause(req)
 	if eb.Err != nil {
 		return nil, eb.Err
 	}
@@ -111,8 +149,8 @@ func (d *genDriver) Get(req doc.GetRequest, a doc.Allocator) (*doc.Optional, err
 		s += "DISTINCT "
 	}
 	s += selectFields + " FROM " + meta.table + where + ";"
-	// fmt.Println("QUERY 1", s)
-	rows, err := d.db.Query(s)
+	// fmt.Println("QUERY 1", s, args)
+	rows, err := d.db.Query(s, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -122,17 +160,23 @@ func (d *genDriver) Get(req doc.GetRequest, a doc.Allocator) (*doc.Optional, err
 	for i := range dest {
 		dest[i] = new(any)
 	}
+	values := make([]any, fieldCount)
 
-	vreq := reflect.SetRequest{
+	vreq := genSetFields(reflect.SetRequest{
 		FieldNames: fields,
-		NewValues:  dest,
+		NewValues:  values,
 		Assigns:    assigns,
-	}
+	})
 
 	for rows.Next() {
 		if err = rows.Scan(dest...); err != nil {
 			return nil, err
 		}
+		// NULL values are nil, which are skipped,
+		// leaving the field at its zero value.
+		for i, v := range dest {
+			values[i] = *v.(*any)
+		}
 		resp := a.New()
 		if err = reflect.Set(vreq, resp); err != nil {
 			return nil, err
@@ -155,40 +199,29 @@ func (d *genDriver) prepareGet(req doc.GetRequest, a doc.Allocator) (*genMetadat
 	if !ok {
 		return nil, nil, nil, nil, fmt.Errorf("missing tabledef for \"%v\"", tn)
 	}
-	assigns := tableDef.AssignsFor(tags)
+	assigns := tableDef.AssignsFor(tags, d.keys, meta.table)
 	return meta, tags, fields, assigns, nil
 }
 
 func (d *genDriver) Delete(req doc.DeleteRequestAny, a doc.Allocator) (*doc.Optional, error) {
-	meta, ok := genMetadatas[a.TypeName()]
-	if !ok {
-		return nil, fmt.Errorf("missing metadata for \"%v\"", a.TypeName())
-	}
-	keys, ok := meta.keys[""]
-	if !ok {
-		return nil, fmt.Errorf("missing primary key metadata for \"%v\"", a.TypeName())
-	}
-
-	opts := reflect.SliceOpts{Assign: doc.AssignKeyword, Combine: doc.AndKeyword}
-	exprSlice := reflect.GetAsSlice(req.ItemAny(), reflect.NewChain(keys.FieldsToTags()), &opts)
-	expr := ""
-	dexpr, err := doc.NewExpr(d.format, exprSlice...)
+	meta, keys, cols, err := d.prepareWrite(a)
 	if err != nil {
 		return nil, err
-	} else {
-		s, err := dexpr.Format()
-		if err != nil {
-			return nil, err
-		}
-		expr = s
 	}
 
+	eb := &errors.FirstBlock{}
+	handler := &fieldsAndValuesHandler{cols: cols}
+	genGetFields(req.ItemAny(), keys.FieldsToTags(), handler)
 	s := genDelSql
 	s = strings.ReplaceAll(s, genTableVar, meta.table)
-	s = strings.ReplaceAll(s, genKeyValuesVar, expr)
-	// fmt.Println("delete statemet", s)
+	s = strings.ReplaceAll(s, genKeyValuesVar, makeKeyValues(eb, handler.fields))
+	eb.AddError(handler.err)
+	if eb.Err != nil {
+		return nil, eb.Err
+	}
+	// fmt.Println("delete statemet", s, handler.values)
 
-	if _, err := d.db.Exec(s); err != nil {
+	if _, err := d.db.Exec(s, handler.values...); err != nil {
 		return nil, err
 	}
 	return nil, nil
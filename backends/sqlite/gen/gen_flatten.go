@@ -0,0 +1,115 @@
+package sqlitegendriver
+
+// autogenerated with github.com/hackborn/doc_drivers
+// do not modify
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	ofreflect "github.com/hackborn/onefunc/reflect"
+)
+
+// genGetFields calls h with the tag and value of every field
+// in fieldsToTags, the same as reflect.Get with the map in the
+// chain, except that the fields of flattened structs are
+// found by their path, i.e. "Address.City".
+func genGetFields(item any, fieldsToTags map[string]string, h ofreflect.GetHandler) {
+	chain := ofreflect.NewChain(fieldsToTags, h)
+	parents := genFieldParents(fieldsToTags)
+	if len(parents) < 1 {
+		ofreflect.Get(item, chain)
+		return
+	}
+	genGetStruct(reflect.Indirect(reflect.ValueOf(item)), "", parents, chain)
+}
+
+func genGetStruct(v reflect.Value, prefix string, parents map[string]bool, h ofreflect.GetHandler) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanInterface() {
+			continue
+		}
+		name := prefix + t.Field(i).Name
+		if parents[name] && f.Kind() == reflect.Struct {
+			genGetStruct(f, name+".", parents, h)
+			continue
+		}
+		h.Handle(name, f.Interface())
+	}
+}
+
+// genFieldParents answers the path of every flattened
+// struct in the field names.
+func genFieldParents(fieldsToTags map[string]string) map[string]bool {
+	var parents map[string]bool
+	for field := range fieldsToTags {
+		for i, r := range field {
+			if r != '.' {
+				continue
+			}
+			if parents == nil {
+				parents = make(map[string]bool)
+			}
+			parents[field[:i]] = true
+		}
+	}
+	return parents
+}
+
+// genSetFields answers the request with the fields of
+// flattened structs, which reflect.Set can't find by path,
+// set through the top-level field that contains them.
+func genSetFields(req ofreflect.SetRequest) ofreflect.SetRequest {
+	if !slices.ContainsFunc(req.FieldNames, func(name string) bool {
+		return strings.Contains(name, ".")
+	}) {
+		return req
+	}
+	names := slices.Clone(req.FieldNames)
+	assigns := make([]ofreflect.SetFunc, len(names))
+	copy(assigns, req.Assigns)
+	for i, name := range names {
+		if top, path, ok := strings.Cut(name, "."); ok {
+			names[i] = top
+			assigns[i] = genPathSetFunc(path, assigns[i], req.Flags)
+		}
+	}
+	req.FieldNames, req.Assigns = names, assigns
+	return req
+}
+
+// genPathSetFunc answers a function that sets the field at
+// path inside dst, using assign if it isn't nil.
+func genPathSetFunc(path string, assign ofreflect.SetFunc, flags uint8) ofreflect.SetFunc {
+	return func(src, dst reflect.Value) error {
+		name := path
+		if i := strings.LastIndex(path, "."); i >= 0 {
+			dst, name = genFieldByPath(dst, path[:i]), path[i+1:]
+		}
+		if !dst.IsValid() || !dst.CanAddr() {
+			return fmt.Errorf("no field for %v", path)
+		}
+		req := ofreflect.SetRequest{FieldNames: []string{name},
+			NewValues: []any{src.Interface()},
+			Assigns:   []ofreflect.SetFunc{assign},
+			Flags:     flags,
+		}
+		return ofreflect.Set(req, dst.Addr().Interface())
+	}
+}
+
+// genFieldByPath answers the field at the path, i.e.
+// "Address.City", or an invalid value if there is none.
+func genFieldByPath(v reflect.Value, path string) reflect.Value {
+	for _, name := range strings.Split(path, ".") {
+		v = v.FieldByName(name)
+		if !v.IsValid() {
+			return v
+		}
+	}
+	return v
+}
@@ -5,10 +5,14 @@ package sqlitegendriver
 
 import (
 	"cmp"
-	"encoding/json"
 	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/hackborn/doc"
+	"github.com/hackborn/doc_drivers/driverkit"
 	"github.com/hackborn/onefunc/errors"
 	ofstrings "github.com/hackborn/onefunc/strings"
 )
@@ -34,22 +38,33 @@ func (f *genFormat) Keyword(s string) string {
 	return s
 }
 
+// Value answers a placeholder for every value. The values
+// themselves are bound as statement arguments, see whereClause().
 func (f *genFormat) Value(v interface{}) (string, error) {
-	s := fmt.Sprintf("%v", v)
-	switch v.(type) {
-	case string:
-		c := string('\'')
-		s = c + s + c
-	}
-	return s, nil
+	return genPlaceholder, nil
 }
 
 type fieldsAndValuesHandler struct {
 	err    error
 	fields []any
 	values []any
-	cols   []genSqlTableCol
-	filter doc.Filter // Accept / reject adding to fields/values based on filter.
+	// updates are the fields replaced when the row exists,
+	// and updateValues are their values.
+	updates      []any
+	updateValues []any
+	cols         []genSqlTableCol
+	filter       doc.Filter // Accept / reject adding to fields/values based on filter.
+	now          time.Time
+	// version is the item's version, if the table has one.
+	version *genVersion
+	// keys seal the encrypted columns of the table.
+	keys  driverkit.KeyProvider
+	table string
+}
+
+type genVersion struct {
+	name  string
+	value int64
 }
 
 func (h *fieldsAndValuesHandler) Handle(name string, value any) (string, any) {
@@ -58,25 +73,106 @@ func (h *fieldsAndValuesHandler) Handle(name string, value any) (string, any) {
 		return name, value
 	}
 	h.fields = append(h.fields, name)
+	if col.flags&(colFlagCreated|colFlagUpdated) != 0 {
+		value = genStampValue(value, h.now)
+	}
+	// The stored version is the next one.
+	if col.flags&colFlagVersion != 0 {
+		v, err := genVersionValue(value)
+		h.err = cmp.Or(h.err, err)
+		h.version = &genVersion{name: name, value: v}
+		value = v + 1
+	}
 	// Deal with any values that can't be stored directly in the DB by formatting them.
 	formatted := h.formatValue(col, value)
 	h.values = append(h.values, formatted)
+	// Created is only written with the insert, unless this is
+	// explicitly a new item.
+	if col.flags&colFlagCreated == 0 || h.filter.Rule == doc.RuleCreateItem {
+		h.updates = append(h.updates, name)
+		h.updateValues = append(h.updateValues, formatted)
+	}
 	return name, value
 }
 
-// formatValue applies any desired formmating to the value.
-func (h *fieldsAndValuesHandler) formatValue(col genSqlTableCol, value any) any {
-	switch col.format {
-	case "json":
-		if dat, err := json.Marshal(value); err == nil {
-			return string(dat)
-		} else {
-			h.err = cmp.Or(h.err, err)
-			return value
+// updateArgs answers the arguments for the version update
+// statement: the updated values, the keys, then the version.
+func (h *fieldsAndValuesHandler) updateArgs(keys []string) []any {
+	args := slices.Clone(h.updateValues)
+	for _, key := range keys {
+		if i := slices.Index(h.fields, any(key)); i >= 0 {
+			args = append(args, h.values[i])
 		}
+	}
+	return append(args, h.version.value)
+}
+
+// genVersionValue answers the integer version as an int64.
+func genVersionValue(value any) (int64, error) {
+	v := reflect.ValueOf(value)
+	switch {
+	case v.CanInt():
+		return v.Int(), nil
+	case v.CanUint():
+		return int64(v.Uint()), nil
 	default:
+		return 0, fmt.Errorf("Version must be an integer, has %T", value)
+	}
+}
+
+// genNextVersion copies the item into dst, with the
+// version field set to the version it was stored with.
+func genNextVersion(dst, item any, field string, version int64) error {
+	v := reflect.ValueOf(dst).Elem()
+	v.Set(reflect.Indirect(reflect.ValueOf(item)))
+	fv := genFieldByPath(v, field)
+	switch {
+	case fv.CanInt():
+		fv.SetInt(version)
+	case fv.CanUint():
+		fv.SetUint(uint64(version))
+	default:
+		return fmt.Errorf("Version must be an integer, has \"%v\"", field)
+	}
+	return nil
+}
+
+// genStampValue answers now in the type of the timestamp
+// field, either a time.Time or milliseconds since the epoch.
+func genStampValue(value any, now time.Time) any {
+	if _, ok := value.(time.Time); ok {
+		return now
+	}
+	return now.UnixMilli()
+}
+
+// formatValue applies any desired formmating to the value.
+// Formatted values are stored as bytes in BLOB columns,
+// and as strings in every other column. Encrypted and
+// compressed values are always stored as bytes.
+func (h *fieldsAndValuesHandler) formatValue(col genSqlTableCol, value any) any {
+	if col.format == "" {
+		return value
+	}
+	f, err := driverkit.FindFormat(col.format)
+	if err != nil {
+		h.err = cmp.Or(h.err, err)
 		return value
 	}
+	dat, err := f.Marshal(value)
+	if err != nil {
+		h.err = cmp.Or(h.err, err)
+		return value
+	}
+	if col.flags&(colFlagEncrypt|colFlagGzip|colFlagFlate) != 0 {
+		dat, err = col.encode(dat, h.keys, h.table)
+		h.err = cmp.Or(h.err, err)
+		return dat
+	}
+	if strings.Contains(col.dbType, "BLOB") {
+		return dat
+	}
+	return string(dat)
 }
 
 func makeExcludedFieldValues(eb errors.Block, names []any) string {
@@ -93,27 +189,94 @@ func makeExcludedFieldValues(eb errors.Block, names []any) string {
 	return ofstrings.String(w)
 }
 
+// makeAssignments answers a list assigning a placeholder to each name.
+func makeAssignments(eb errors.Block, names []any) string {
+	w := ofstrings.GetWriter(eb)
+	defer ofstrings.PutWriter(w)
+
+	for i, n := range names {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.WriteString(fmt.Sprintf("%v", n) + genEqualsKeywordWS + genPlaceholder)
+	}
+	return ofstrings.String(w)
+}
+
+// makePlaceholders answers a list of count placeholders.
+func makePlaceholders(eb errors.Block, count int) string {
+	w := ofstrings.GetWriter(eb)
+	defer ofstrings.PutWriter(w)
+
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.WriteString(genPlaceholder)
+	}
+	return ofstrings.String(w)
+}
+
+// makeKeyValues answers an AND clause assigning a placeholder to each name.
+func makeKeyValues(eb errors.Block, names []any) string {
+	w := ofstrings.GetWriter(eb)
+	defer ofstrings.PutWriter(w)
+
+	for i, n := range names {
+		if i > 0 {
+			w.WriteString(genAndKeywordWS)
+		}
+		w.WriteString(fmt.Sprintf("%v", n) + genEqualsKeywordWS + genPlaceholder)
+	}
+	return ofstrings.String(w)
+}
+
 func getFormats(fields []string) []string {
 	s := make([]string, 0, len(fields))
 	return s
 }
 
-func whereClause(req doc.GetRequest) (string, error) {
+// whereClause answers the WHERE clause for the request condition. Every
+// value in the clause is a placeholder, and the values are answered in order.
+// The format and the extractor walk the same expression, so there is one
+// value for every placeholder the format writes. The formatted text isn't
+// counted, since names and keywords can also contain the placeholder.
+func whereClause(req doc.GetRequest) (string, []any, error) {
 	if req.Condition == nil {
-		return "", nil
+		return "", nil, nil
 	}
 	expr, err := req.Condition.Compile()
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	s, err := expr.Format()
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	if s == "" {
-		return "", nil
+		return "", nil, nil
 	}
-	return " WHERE " + s, nil
+	args := &whereArgsHandler{}
+	err = expr.Extract(args)
+	if err != nil {
+		return "", nil, err
+	}
+	return " WHERE " + s, args.values, nil
+}
+
+// whereArgsHandler collects the values from a condition, in
+// the same order the format writes the placeholders.
+type whereArgsHandler struct {
+	values []any
+}
+
+func (h *whereArgsHandler) BinaryConjunction(keyword string) error {
+	return nil
+}
+
+func (h *whereArgsHandler) BinaryAssignment(lhs string, rhs any) error {
+	h.values = append(h.values, rhs)
+	return nil
 }
 
 func getColByName(name string, cols []genSqlTableCol) genSqlTableCol {
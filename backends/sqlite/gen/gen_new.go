@@ -0,0 +1,19 @@
+package sqlitegendriver
+
+// autogenerated with github.com/hackborn/doc_drivers
+// do not modify
+
+import (
+	"github.com/hackborn/doc"
+	"github.com/hackborn/doc_drivers/driverkit"
+)
+
+func NewDriver(name string) doc.Driver {
+	return &genDriver{sqlDriverName: name}
+}
+
+// NewDriverWithKeys answers a driver that seals the fields
+// tagged encrypt with keys from the provider.
+func NewDriverWithKeys(name string, keys driverkit.KeyProvider) doc.Driver {
+	return &genDriver{sqlDriverName: name, keys: keys}
+}
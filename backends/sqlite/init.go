@@ -11,6 +11,7 @@ import (
 	sqlitegendriver "github.com/hackborn/doc_drivers/backends/sqlite/gen"
 	"github.com/hackborn/doc_drivers/backends/sqlite/nodes"
 	sqliterefdriver "github.com/hackborn/doc_drivers/backends/sqlite/ref"
+	"github.com/hackborn/doc_drivers/driverkit"
 	"github.com/hackborn/doc_drivers/graphs"
 	"github.com/hackborn/doc_drivers/registry"
 	"github.com/hackborn/onefunc/errors"
@@ -42,6 +43,12 @@ func addGraphs(m map[string]graphs.Entry) {
 	for k, v := range entries {
 		m[k] = v
 	}
+	// Internal graphs are run by MakeDriver.
+	entries, _ = graphs.ReadEntries(graphsFs, "graphs/internal/*.txt")
+	for k, v := range entries {
+		v.Hidden = true
+		m[k] = v
+	}
 }
 
 func newOpenFunc(f registry.Factory) func() error {
@@ -56,10 +63,10 @@ func newOpenFunc(f registry.Factory) func() error {
 
 		// Make drivers accessible to nodes without going through the backend
 		refFn := func() doc.Driver {
-			return sqliterefdriver.NewDriver(nodes.FormatSqlite)
+			return sqliterefdriver.NewDriverWithKeys(nodes.FormatSqlite, driverkit.StaticKey(devKey))
 		}
 		genFn := func() doc.Driver {
-			return sqlitegendriver.NewDriver(nodes.FormatSqlite)
+			return sqlitegendriver.NewDriverWithKeys(nodes.FormatSqlite, driverkit.StaticKey(devKey))
 		}
 		doc.Register("ref/"+nodes.FormatSqlite, refFn())
 		doc.Register("gen/"+nodes.FormatSqlite, genFn())
@@ -68,7 +75,10 @@ func newOpenFunc(f registry.Factory) func() error {
 	}
 }
 
-//go:embed graphs/*
+// devKey seals the encrypted fields of the development drivers.
+var devKey = []byte("doc_drivers development key 0001")
+
+//go:embed graphs/* graphs/internal/*
 var graphsFs embed.FS
 
 //go:embed ref/*
@@ -1,13 +1,25 @@
 package nodes
 
+import (
+	"github.com/hackborn/doc_drivers/enc"
+)
+
 const (
 	FormatSqlite   = "sqlite"
 	TemplateFsName = FormatSqlite + "templates"
 
 	definitionKey = "def"
 
-	templateDatestampKey   = "{{.Datestamp}}"
 	templatePrefixKey      = "{{.Prefix}}"
 	templatePackageKey     = "{{.Package}}"
 	templateUtilPackageKey = "{{.UtilPackage}}"
 )
+
+var (
+	// tagSupport is every tag keyword and flag sqlite honors. There's
+	// no nesting in a table, so local autoincs have no meaning.
+	tagSupport = enc.Support{Backend: FormatSqlite,
+		Keywords: []string{"name", "key", "format", "autoinc", "index", "unique", "type", "size", "default", "notnull", "nullable", "created", "updated", "version", "inline", "flatten", "was", "encrypt", "compress"},
+		Flags:    enc.FlagAutoIncGlobal,
+	}
+)
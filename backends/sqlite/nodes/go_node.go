@@ -7,14 +7,16 @@ import (
 	"io/fs"
 	"path"
 	"slices"
+	"strconv"
 	"strings"
 	"text/template"
-	"time"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
+	"github.com/hackborn/doc_drivers/enc"
 	"github.com/hackborn/doc_drivers/registry"
+	"github.com/hackborn/doc_drivers/schema"
 	"github.com/hackborn/onefunc/errors"
 	"github.com/hackborn/onefunc/pipeline"
 	ofstrings "github.com/hackborn/onefunc/strings"
@@ -53,6 +55,17 @@ type goNodeSharedData struct {
 	// created anew with each driver run. Only used during
 	// development.
 	DropTables bool
+
+	// Various configurable properties.
+	Flags string
+
+	// Naming converts the table and column names that
+	// aren't tagged. If empty, column names are lowercased.
+	Naming string
+
+	// If true, a hash of the inputs is added to the
+	// header of every generated file.
+	InputHash bool
 }
 
 type goNodeData struct {
@@ -63,6 +76,7 @@ type goNodeData struct {
 	structs     map[string]*pipeline.StructData
 	definitions map[string]string
 	metadata    map[string]string
+	tables      []schema.Table
 }
 
 func (n *goNodeData) fileName(base, format string) string {
@@ -89,14 +103,17 @@ func (n *goNode) Start(input pipeline.StartInput) error {
 
 func (n *goNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
 	data := state.NodeData.(*goNodeData)
-	eb := &errors.FirstBlock{}
+	if err := enc.CheckNaming(data.Naming); err != nil {
+		return err
+	}
+	eb := &enc.ListBlock{}
 	for _, pin := range input.Pins {
 		switch p := pin.Payload.(type) {
 		case *pipeline.StructData:
 			eb.AddError(n.runStructPin(data, p))
 		}
 	}
-	return eb.Err
+	return eb.Err()
 }
 
 func (n *goNode) Flush(state *pipeline.State, output *pipeline.RunOutput) error {
@@ -111,7 +128,16 @@ func (n *goNode) Flush(state *pipeline.State, output *pipeline.RunOutput) error
 	if err != nil {
 		return fmt.Errorf("go node makeTemplates err: %w", err)
 	}
-	return err
+	slices.SortFunc(data.tables, func(a, b schema.Table) int {
+		return strings.Compare(a.Struct, b.Struct)
+	})
+	sch := &schema.Schema{Format: data.Format, Tables: data.tables}
+	manifest, err := sch.Manifest()
+	if err != nil {
+		return fmt.Errorf("go node manifest err: %w", err)
+	}
+	output.Pins = append(output.Pins, pipeline.Pin{Payload: manifest}, pipeline.Pin{Payload: sch})
+	return nil
 }
 
 func (n *goNode) runStructPin(data *goNodeData, pin *pipeline.StructData) error {
@@ -126,14 +152,14 @@ func (n *goNode) runStructPin(data *goNodeData, pin *pipeline.StructData) error
 
 func (n *goNode) runStructPinSqlite(nodeData *goNodeData, pin *pipeline.StructData) error {
 	/*
-		sn := newSqlNode(nodeData.TablePrefix, nodeData.DropTables)
+		sn := newSqlNode(nodeData.TablePrefix, nodeData.Naming, nodeData.DropTables)
 		output := &pipeline.RunOutput{}
 		err := sn.Run(state, pipeline.NewRunInput(pipeline.Pin{Payload: pin}), output)
 		if err != nil {
 			return err
 		}
 	*/
-	sn := newSqlNode(nodeData.TablePrefix, nodeData.DropTables)
+	sn := newSqlNode(nodeData.TablePrefix, nodeData.Naming, nodeData.DropTables)
 	output := &pipeline.RunOutput{}
 	err := pipeline.RunNode(sn, pipeline.NewRunInput(pipeline.Pin{Payload: pin}), output)
 	if err != nil {
@@ -141,12 +167,14 @@ func (n *goNode) runStructPinSqlite(nodeData *goNodeData, pin *pipeline.StructDa
 	}
 
 	// Metadata
-	eb := errors.FirstBlock{}
-	content := n.makeMetadataValue(nodeData, pin, &eb)
-	if content == "" {
-		return nil
+	md, ok, err := makeMetadata(pin, nodeData.TablePrefix, nodeData.Naming)
+	if !ok {
+		return err
 	}
-	nodeData.metadata[pin.Name] = content
+	eb := errors.FirstBlock{}
+	eb.AddError(err)
+	nodeData.metadata[pin.Name] = n.makeMetadataValue(nodeData, md, &eb)
+	nodeData.tables = append(nodeData.tables, md.schemaTable(pin))
 	if eb.Err != nil {
 		return eb.Err
 	}
@@ -167,12 +195,7 @@ func (n *goNode) runStructPinSqlite(nodeData *goNodeData, pin *pipeline.StructDa
 	return nil
 }
 
-func (n *goNode) makeMetadataValue(nodeData *goNodeData, pin *pipeline.StructData, eb errors.Block) string {
-	md, ok, err := makeMetadata(pin, nodeData.TablePrefix)
-	if !ok {
-		return ""
-	}
-	eb.AddError(err)
+func (n *goNode) makeMetadataValue(nodeData *goNodeData, md metadata, eb errors.Block) string {
 	fn := md.FieldNames()
 	tn := md.TagNames()
 
@@ -185,7 +208,13 @@ func (n *goNode) makeMetadataValue(nodeData *goNodeData, pin *pipeline.StructDat
 	w.WriteString("\t\t\ttags: []string{" + ofstrings.CompileStrings(ca, tn...) + "},\n")
 	w.WriteString("\t\t\tfields: []string{" + ofstrings.CompileStrings(ca, fn...) + "},\n")
 	w.WriteString("\t\t\tkeys: map[string]*" + nodeData.Prefix + "KeyMetadata{\n")
+	keyNames := make([]string, 0, len(md.Keys))
 	for k := range md.Keys {
+		keyNames = append(keyNames, k)
+	}
+	// Sorted so the output is identical between runs.
+	slices.Sort(keyNames)
+	for _, k := range keyNames {
 		w.WriteString("\t\t\t\t\"" + k + "\": {\n")
 		w.WriteString("\t\t\t\t\ttags: []string{" + ofstrings.CompileStrings(ca, md.KeyTagNames(k)...) + "},\n")
 		w.WriteString("\t\t\t\t\tfields: []string{" + ofstrings.CompileStrings(ca, md.KeyFieldNames(k)...) + "},\n")
@@ -204,6 +233,10 @@ func (n *goNode) makeTemplates(nodeData *goNodeData, vars map[string]any, output
 	eb := &errors.FirstBlock{}
 	matches, err := fs.Glob(templatesFs, "templates/*.txt")
 	eb.AddError(err)
+	hash := ""
+	if nodeData.InputHash {
+		hash = n.makeInputHash(nodeData)
+	}
 
 	for _, match := range matches {
 		d, err := fs.ReadFile(templatesFs, match)
@@ -214,12 +247,25 @@ func (n *goNode) makeTemplates(nodeData *goNodeData, vars map[string]any, output
 		eb.AddError(err)
 		if err == nil {
 			name := nodeData.fileName(path.Base(match), ".go")
-			output.Pins = append(output.Pins, pipeline.Pin{Payload: &pipeline.ContentData{Name: name, Data: string(b)}})
+			content := string(b)
+			if hash != "" {
+				content = registry.AddInputHash(content, hash)
+			}
+			output.Pins = append(output.Pins, pipeline.Pin{Payload: &pipeline.ContentData{Name: name, Data: content}})
 		}
 	}
 	return eb.Err
 }
 
+func (n *goNode) makeInputHash(nodeData *goNodeData) string {
+	settings := []string{nodeData.Format, nodeData.Pkg, nodeData.Prefix, nodeData.TablePrefix, strconv.FormatBool(nodeData.DropTables), nodeData.Flags, nodeData.Naming}
+	structs := make([]*pipeline.StructData, 0, len(nodeData.structs))
+	for _, v := range nodeData.structs {
+		structs = append(structs, v)
+	}
+	return registry.InputHash(settings, structs)
+}
+
 func (n *goNode) runTemplate(content string, vars map[string]any) ([]byte, error) {
 	eb := errors.FirstBlock{}
 	t1 := template.New("t1")
@@ -263,6 +309,5 @@ func (n *goNode) makeVars(nodeData *goNodeData) (map[string]any, error) {
 		return strings.Compare(a.Name, b.Name)
 	})
 	m["Metadata"] = metadatas
-	m["Datestamp"] = time.Now().Format(time.DateOnly)
 	return m, nil
 }
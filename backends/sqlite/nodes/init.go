@@ -5,7 +5,7 @@ import (
 )
 
 func RegisterNodes() {
-	pipeline.RegisterNode("go", func() pipeline.Node {
+	pipeline.RegisterNode(FormatSqlite+"go", func() pipeline.Node {
 		return newGoNode()
 	})
 	// Only used from inside the go node
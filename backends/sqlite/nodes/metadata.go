@@ -1,9 +1,13 @@
 package nodes
 
 import (
+	"cmp"
+	"fmt"
 	"slices"
 	"strings"
 
+	"github.com/hackborn/doc_drivers/enc"
+	"github.com/hackborn/doc_drivers/schema"
 	oferrors "github.com/hackborn/onefunc/errors"
 	"github.com/hackborn/onefunc/pipeline"
 	ofslices "github.com/hackborn/onefunc/slices"
@@ -12,9 +16,11 @@ import (
 // metadata is parallel to pipeline.StructData, except with parsed tags.
 type metadata struct {
 	// The table name
-	Name   string
-	Fields []structField
-	Keys   map[string][]structKey
+	Name    string
+	Fields  []structField
+	Keys    map[string][]structKey
+	Indexes []indexSpec // Secondary indexes, sorted by name.
+	Uniques []indexSpec // Unique constraints, sorted by name.
 }
 
 func (d metadata) TagNames() []string {
@@ -48,7 +54,7 @@ func (d metadata) KeySpecs() keySpecList {
 		for _, key := range v {
 			keySpec := keySpec{Field: key.Field, ColumnName: key.Tag}
 			if field, ok := d.fieldForTag(key.Tag); ok {
-				keySpec.DbType = convertGoTypeToSQLType(field.Type)
+				keySpec.DbType = field.DbType
 			}
 			groupSpec.keys = append(groupSpec.keys, keySpec)
 		}
@@ -61,6 +67,42 @@ func (d metadata) KeySpecs() keySpecList {
 	return list
 }
 
+// schemaTable answers the public description of this metadata.
+func (d metadata) schemaTable(pin *pipeline.StructData) schema.Table {
+	t := schema.Table{Struct: pin.Name, Name: d.Name}
+	keys := d.KeySpecs()
+	for _, f := range d.Fields {
+		// Report the declared type, not the primitive it's stored as.
+		col := schema.Column{Field: f.Field, Name: f.Tag, Type: f.Type}
+		if idx := slices.IndexFunc(pin.Fields, func(sf pipeline.StructField) bool { return sf.Name == f.Field }); idx >= 0 {
+			col.Type = pin.Fields[idx].RawType
+		}
+		col.DbType = f.DbType
+		col.Format = f.Format
+		if keys.hasColumn(f.Tag) {
+			col.Flags = append(col.Flags, "key")
+		}
+		col.Flags = append(col.Flags, f.columnFlags()...)
+		if f.Default != nil {
+			col.Default = fmt.Sprint(f.Default)
+		}
+		col.Extra = f.Extra
+		col.Was = f.Was
+		col.Compress = f.Compress
+		t.Columns = append(t.Columns, col)
+	}
+	for _, g := range keys.keyGroups {
+		t.Keys = append(t.Keys, schema.KeyGroup{Name: g.name, Columns: g.columnNames()})
+	}
+	for _, idx := range d.Indexes {
+		t.Indexes = append(t.Indexes, schema.Index{Name: idx.Name, Columns: idx.columnNames(), Unique: idx.Unique})
+	}
+	for _, u := range d.Uniques {
+		t.Uniques = append(t.Uniques, schema.KeyGroup{Name: u.Name, Columns: u.columnNames()})
+	}
+	return t
+}
+
 func (d metadata) fieldForTag(tag string) (structField, bool) {
 	for _, sf := range d.Fields {
 		if sf.Tag == tag {
@@ -74,7 +116,50 @@ type structField struct {
 	Tag    string
 	Field  string
 	Type   string
+	DbType string // The column type.
 	Format string // A format to translate to when storing in the database.
+	Flags  enc.Flags
+	Extra  map[string]any // Values set by registered tag keywords.
+	// Default is the typed default value, or nil.
+	Default  any
+	NotNull  bool
+	Nullable bool
+	// Created and Updated are timestamps set by the driver.
+	Created bool
+	Updated bool
+	// Version is incremented by the driver on every write.
+	Version bool
+	// Was are the previous names of the column,
+	// which are renamed when the table is synced.
+	Was []string
+	// Encrypt seals the value with the table key.
+	Encrypt bool
+	// Compress is the compression algorithm, if any.
+	Compress string
+}
+
+// columnFlags answers the flags for the schema, in their tag form.
+func (f structField) columnFlags() []string {
+	flags := f.Flags.Names()
+	if f.NotNull {
+		flags = append(flags, "notnull")
+	}
+	if f.Nullable {
+		flags = append(flags, "nullable")
+	}
+	if f.Created {
+		flags = append(flags, "created")
+	}
+	if f.Updated {
+		flags = append(flags, "updated")
+	}
+	if f.Version {
+		flags = append(flags, "version")
+	}
+	if f.Encrypt {
+		flags = append(flags, "encrypt")
+	}
+	return flags
 }
 
 type structKey struct {
@@ -82,6 +167,19 @@ type structKey struct {
 	Field string
 }
 
+// indexSpec describes a secondary index or unique constraint.
+type indexSpec struct {
+	Name   string
+	Unique bool
+	Keys   []structKey
+}
+
+func (s indexSpec) columnNames() []string {
+	return ofslices.ArrayFrom(s.Keys, func(key structKey) string {
+		return key.Tag
+	})
+}
+
 type parsedKey struct {
 	name     string
 	position int
@@ -92,16 +190,38 @@ type parsedKey struct {
 
 // makeMetadata answers the results of parsing the struct
 // data, including the tags, into a parallel structure.
+// Naming converts the table and column names that aren't
+// tagged; if empty, columns are lowercased.
 // The bool is set to false if this metadata should be skipped.
-func makeMetadata(pin *pipeline.StructData, tablePrefix string) (metadata, bool, error) {
-	eb := oferrors.FirstBlock{}
+func makeMetadata(pin *pipeline.StructData, tablePrefix, naming string) (metadata, bool, error) {
+	// Collect every error, so one run reports all the problems.
+	eb := enc.ListBlock{}
 	md := metadata{Name: pin.Name}
+	// Skipped tables don't need their fields parsed.
+	naming = makeTableMetadata(pin, &md, naming, &eb)
+	if md.Name == "-" {
+		return metadata{}, false, eb.Err()
+	}
 	md.Keys = make(map[string][]structKey)
 	keys := make(map[string][]*parsedKey)
+	indexes := make(map[string][]*parsedKey)
+	unique := make(map[string]bool)
 	for _, f := range pin.Fields {
-		pt, err := parseTag(f.Tag)
-		eb.AddError(err)
+		pt, err := enc.ParseTag(f.Tag)
+		err = cmp.Or(err, pt.Validate(), pt.CheckType(f.RawType), tagSupport.Check(pt))
+		eb.AddError(enc.NewFieldError(pin.Name, f.Name, err))
 		sf, pk := convertToLocal(f, pt)
+		// Types that can't be stored directly are stored as json,
+		// as are encrypted and compressed values, which are bytes.
+		if (sf.Type == pipeline.UnknownType || pt.Encrypt || pt.Compress != "") && sf.Format == "" {
+			sf.Format = "json"
+		}
+		sf.DbType, err = columnType(sf.Type, sf.Format, pt)
+		eb.AddError(enc.NewFieldError(pin.Name, f.Name, err))
+		if pt.HasDefault {
+			sf.Default, err = pt.DefaultValue(sf.Type)
+			eb.AddError(enc.NewFieldError(pin.Name, f.Name, err))
+		}
 		// Skip indicator
 		if sf.Tag == "-" {
 			continue
@@ -109,7 +229,12 @@ func makeMetadata(pin *pipeline.StructData, tablePrefix string) (metadata, bool,
 		// Default field name indicator.
 		if sf.Tag == "" {
 			// SQLITE convention is lowercase names
-			sf.Tag = strings.ToLower(sf.Field)
+			sf.Tag = enc.ApplyNaming(cmp.Or(naming, enc.NamingLower), baseFieldName(sf.Field))
+		}
+		sf.Tag = pt.Flatten + sf.Tag
+		if prev, ok := md.fieldForTag(sf.Tag); ok {
+			eb.AddError(enc.NewFieldError(pin.Name, f.Name, fmt.Errorf("Column \"%v\" is already used by %v", sf.Tag, prev.Field)))
+			continue
 		}
 		md.Fields = append(md.Fields, sf)
 		if pk != nil {
@@ -122,10 +247,30 @@ func makeMetadata(pin *pipeline.StructData, tablePrefix string) (metadata, bool,
 				keys[pk.name] = []*parsedKey{pk}
 			}
 		}
+		for _, it := range pt.Indexes {
+			// Unnamed indexes are named after their column.
+			name := cmp.Or(it.Name, sf.Tag)
+			indexes[name] = append(indexes[name], &parsedKey{name: name, position: it.Index, tagName: sf.Tag, fieldName: sf.Field})
+			unique[name] = unique[name] || it.Unique
+		}
+		for _, u := range pt.Uniques {
+			// Unnamed constraints are named after their column.
+			md.Uniques = addUnique(md.Uniques, cmp.Or(u, sf.Tag), structKey{Tag: sf.Tag, Field: sf.Field})
+		}
+	}
+	versions := 0
+	for _, f := range md.Fields {
+		if f.Version {
+			versions++
+		}
+	}
+	if versions > 1 {
+		eb.AddError(enc.NewStructError(pin.Name, fmt.Errorf("Metadata can only have one version field")))
 	}
+	validateWas(pin.Name, md, &eb)
 	// Compile the keys
 	for k, v := range keys {
-		slices.SortFunc(v, func(a, b *parsedKey) int {
+		slices.SortStableFunc(v, func(a, b *parsedKey) int {
 			if a.position < b.position {
 				return -1
 			} else if a.position > b.position {
@@ -140,40 +285,127 @@ func makeMetadata(pin *pipeline.StructData, tablePrefix string) (metadata, bool,
 		}
 		md.Keys[k] = value
 	}
-	makeTableMetadata(pin, &md, &eb)
-	if md.Name == "-" {
-		return metadata{}, false, eb.Err
+	// Compile the indexes
+	for k, v := range indexes {
+		slices.SortStableFunc(v, func(a, b *parsedKey) int {
+			return cmp.Compare(a.position, b.position)
+		})
+		spec := indexSpec{Name: k, Unique: unique[k]}
+		for _, vv := range v {
+			spec.Keys = append(spec.Keys, structKey{Tag: vv.tagName, Field: vv.fieldName})
+		}
+		md.Indexes = append(md.Indexes, spec)
 	}
+	slices.SortFunc(md.Indexes, func(a, b indexSpec) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	slices.SortFunc(md.Uniques, func(a, b indexSpec) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	validateAutoinc(pin.Name, md, &eb)
 	md.Name = tablePrefix + md.Name
-	return md, true, eb.Err
+	return md, true, eb.Err()
+}
+
+// addUnique adds the key to the named constraint. Compound
+// constraints are in field order.
+func addUnique(specs []indexSpec, name string, key structKey) []indexSpec {
+	idx := slices.IndexFunc(specs, func(s indexSpec) bool {
+		return s.Name == name
+	})
+	if idx < 0 {
+		return append(specs, indexSpec{Name: name, Unique: true, Keys: []structKey{key}})
+	}
+	specs[idx].Keys = append(specs[idx].Keys, key)
+	return specs
+}
+
+// validateAutoinc confirms autoinc is only used where sqlite
+// generates the value: a single integer primary key column.
+func validateAutoinc(structName string, md metadata, eb oferrors.Block) {
+	primary := md.Keys[""]
+	for _, f := range md.Fields {
+		if f.Flags&enc.FlagAutoIncGlobal == 0 {
+			continue
+		}
+		var err error
+		if f.DbType != sqlInteger {
+			err = fmt.Errorf("Autoinc must be on an integer type")
+		} else if len(primary) != 1 || primary[0].Field != f.Field {
+			err = fmt.Errorf("Autoinc must be the only column in the primary key")
+		}
+		eb.AddError(enc.NewFieldError(structName, f.Field, err))
+	}
 }
 
-func makeTableMetadata(pin *pipeline.StructData, md *metadata, eb oferrors.Block) {
+// validateWas reports previous names that are still in use,
+// since the rename would collide with the current column.
+func validateWas(structName string, md metadata, eb oferrors.Block) {
+	renamed := make(map[string]string)
+	for _, f := range md.Fields {
+		for _, was := range f.Was {
+			var err error
+			if prev, ok := md.fieldForTag(was); ok {
+				err = fmt.Errorf("Was \"%v\" is the column of %v", was, prev.Field)
+			} else if prev, ok := renamed[was]; ok {
+				err = fmt.Errorf("Was \"%v\" is already renamed to %v", was, prev)
+			}
+			renamed[was] = f.Tag
+			eb.AddError(enc.NewFieldError(structName, f.Field, err))
+		}
+	}
+}
+
+// makeTableMetadata applies the table tags, answering the
+// naming for the columns, which the table tag can override.
+func makeTableMetadata(pin *pipeline.StructData, md *metadata, naming string, eb oferrors.Block) string {
+	name := ""
 	for _, f := range pin.UnexportedFields {
 		// The tag was filtered for my "doc" keyword, so any non-empty
 		// tag will be a table specification
 		if f.Tag == "" {
 			continue
 		}
-		pt, err := parseTag(f.Tag)
-		eb.AddError(err)
-		if pt.name != "" {
-			md.Name = pt.name
+		pt, err := enc.ParseTag(f.Tag)
+		eb.AddError(enc.NewFieldError(pin.Name, f.Name, err))
+		if pt.Name != "" {
+			name = pt.Name
+		}
+		if pt.Naming != "" {
+			naming = pt.Naming
 		}
+		if pt.Compress != "" {
+			eb.AddError(enc.NewFieldError(pin.Name, f.Name, fmt.Errorf("Table compress is not supported by %v, compress the fields instead", FormatSqlite)))
+		}
+	}
+	switch {
+	case name != "":
+		md.Name = name
+	case naming != "":
+		md.Name = enc.ApplyNaming(naming, pin.Name)
 	}
+	return naming
 }
 
 // convertToLocal converts a parsed tag to struct field and parsed key.
-func convertToLocal(f pipeline.StructField, parsed parsedTag) (structField, *parsedKey) {
-	sf := structField{Tag: parsed.name, Field: f.Name}
+func convertToLocal(f pipeline.StructField, parsed enc.Tag) (structField, *parsedKey) {
+	sf := structField{Tag: parsed.Name, Field: f.Name, Format: parsed.Format, Flags: parsed.Flags, Extra: parsed.Extra,
+		NotNull: parsed.NotNull, Nullable: parsed.Nullable, Created: parsed.Created, Updated: parsed.Updated, Version: parsed.Version,
+		Was: parsed.Was, Encrypt: parsed.Encrypt, Compress: parsed.Compress}
 	sf.Type = primitiveFieldType(f.Type)
 	var key *parsedKey
-	if parsed.hasKey {
-		key = &parsedKey{name: parsed.keyGroup, position: parsed.keyIndex}
+	if parsed.HasKey {
+		key = &parsedKey{name: parsed.KeyGroup, position: parsed.KeyIndex}
 	}
 	return sf, key
 }
 
+// baseFieldName answers the last name in the field path.
+// Fields of flattened structs have a path, i.e. "Address.City".
+func baseFieldName(field string) string {
+	return field[strings.LastIndex(field, ".")+1:]
+}
+
 // primitiveFieldType will convert all field types to known primitives,
 // or an unknown type to kick off the serialization.
 func primitiveFieldType(ft string) string {
@@ -213,6 +445,17 @@ func (s keySpecList) isPrimary(tag string) bool {
 	return false
 }
 
+func (s keySpecList) hasColumn(tag string) bool {
+	for _, groupSpec := range s.keyGroups {
+		for _, keySpec := range groupSpec.keys {
+			if keySpec.ColumnName == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type keyGroupSpec struct {
 	name string
 	keys []keySpec
@@ -2,8 +2,10 @@ package nodes
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
+	oferrors "github.com/hackborn/onefunc/errors"
 	"github.com/hackborn/onefunc/jacl"
 	"github.com/hackborn/onefunc/pipeline"
 )
@@ -27,9 +29,37 @@ func TestMetadata(t *testing.T) {
 		{nameStruct, []string{`Fields/3/Field=KeyName1`, `Fields/3/Tag=keyname1`}, nil, nil},
 		{nameStruct, []string{`Keys/""/0/Field=KeyName1`, `Keys/""/0/Tag=keyname1`}, nil, nil},
 		{skipStruct, []string{`Fields/0/Field=Skip1`}, nil, fmt.Errorf("out-of-range because skip fields don't exist")},
+		{indexStruct, []string{`Indexes/{count}=2`, `Indexes/0/Name=a`, `Indexes/0/Unique=true`, `Indexes/0/Keys/0/Tag=second`, `Indexes/0/Keys/1/Tag=first`}, nil, nil},
+		{indexStruct, []string{`Indexes/1/Name=first`, `Indexes/1/Unique=false`, `Indexes/1/Keys/0/Field=First`}, nil, nil},
+		{uniqueStruct, []string{`Uniques/{count}=2`, `Uniques/0/Name=a`, `Uniques/0/Keys/0/Tag=first`, `Uniques/0/Keys/1/Tag=second`}, nil, nil},
+		{uniqueStruct, []string{`Uniques/1/Name=second`, `Uniques/1/Keys/{count}=1`}, nil, nil},
+		{defaultStruct, []string{`Fields/1/Default=usd`, `Fields/1/NotNull=true`, `Fields/2/Default=5`, `Fields/2/Nullable=true`}, nil, nil},
+		{defaultJsonStruct, []string{}, fmt.Errorf("default requires a bool, number or string field"), nil},
+		{nullableKeyStruct, []string{}, fmt.Errorf("nullable can't be set on keys"), nil},
+		{typeStruct, []string{`Fields/1/DbType=TEXT`, `Fields/2/DbType="VARCHAR(16)"`, `Fields/3/DbType="CHAR(4)"`, `Fields/4/DbType=BIGINT`}, nil, nil},
+		{typeStruct, []string{`Fields/5/DbType=BLOB`, `Fields/6/DbType=INTEGER`}, nil, nil},
+		{typeMismatchStruct, []string{}, fmt.Errorf("type TEXT can't store int64"), nil},
+		{sizeIntStruct, []string{}, fmt.Errorf("size requires a string field or a type"), nil},
+		{formatStruct, []string{`Fields/1/Format=gob`, `Fields/1/DbType=BLOB`, `Fields/2/Format=text`, `Fields/2/DbType=TEXT`}, nil, nil},
+		{formatStruct, []string{`Fields/3/Format=json`, `Fields/3/DbType=TEXT`, `Fields/4/Format=""`}, nil, nil},
+		{formatMismatchStruct, []string{}, fmt.Errorf("type INTEGER can't store gob"), nil},
+		{stampStruct, []string{`Fields/1/Created=true`, `Fields/1/Format=json`, `Fields/2/Updated=true`, `Fields/2/DbType=INTEGER`}, nil, nil},
+		{stampStringStruct, []string{}, fmt.Errorf("tag created requires a time.Time or int64 field"), nil},
+		{versionStruct, []string{`Fields/1/Version=true`, `Fields/1/DbType=INTEGER`}, nil, nil},
+		{twoVersionStruct, []string{}, fmt.Errorf("metadata can only have one version field"), nil},
+		{autoincStruct, []string{`Fields/0/Flags=1`}, nil, nil},
+		{autoincLocalStruct, []string{}, fmt.Errorf("local autoinc is not supported"), nil},
+		{autoincIndexStruct, []string{}, fmt.Errorf("autoinc must be the primary key"), nil},
+		{autoincStringStruct, []string{}, fmt.Errorf("autoinc must be an integer"), nil},
+		{wasStruct, []string{`Fields/1/Tag=body`, `Fields/1/Was/0=text`, `Fields/1/Was/1=content`}, nil, nil},
+		{wasInUseStruct, []string{}, fmt.Errorf("was \"note\" is the column of Note"), nil},
+		{encryptStruct, []string{`Fields/1/Tag=ssn`, `Fields/1/Encrypt=true`, `Fields/1/Format=json`, `Fields/1/DbType=BLOB`}, nil, nil},
+		{encryptKeyStruct, []string{}, fmt.Errorf("tag encrypt can't be set on keys"), nil},
+		{compressStruct, []string{`Fields/1/Compress=gzip`, `Fields/1/Format=gob`, `Fields/1/DbType=BLOB`, `Fields/2/Compress=flate`, `Fields/2/Format=json`}, nil, nil},
+		{flattenStruct, []string{`Fields/1/Field="Home.City"`, `Fields/1/Tag=home_city`, `Fields/2/Field="Home.Street"`, `Fields/2/Tag=home_st`}, nil, nil},
 	}
 	for i, v := range table {
-		md, _, haveErr := makeMetadata(v.structData, "")
+		md, _, haveErr := makeMetadata(v.structData, "", "")
 		cmpErr := jacl.Run(md, v.cmp...)
 
 		if v.wantErr == nil && haveErr != nil {
@@ -42,6 +72,48 @@ func TestMetadata(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------
+// TEST-METADATA-NAMING
+func TestMetadataNaming(t *testing.T) {
+	f := func(structData *pipeline.StructData, naming string, cmp ...string) {
+		t.Helper()
+
+		md, _, err := makeMetadata(structData, "", naming)
+		if err != nil {
+			t.Fatalf("Has err %v", err)
+		}
+		if err := jacl.Run(md, cmp...); err != nil {
+			t.Fatalf("Naming %v comparison error: %v", naming, err)
+		}
+	}
+	f(namingStruct, "", `Name=NamingCase`, `Fields/0/Tag=userid`, `Fields/1/Tag=fy`)
+	f(namingStruct, "snake_case", `Name=naming_case`, `Fields/0/Tag=user_id`, `Fields/1/Tag=fy`)
+	f(namingStruct, "camel", `Name=namingCase`, `Fields/0/Tag=userID`)
+	f(namingStruct, "verbatim", `Name=NamingCase`, `Fields/0/Tag=UserID`)
+	f(namingTableStruct, "camel", `Name=companies`, `Fields/0/Tag=user_id`)
+}
+
+// ---------------------------------------------------------
+// TEST-DEFINITION-CREATE
+func TestDefinitionCreate(t *testing.T) {
+	md, _, err := makeMetadata(defaultStruct, "", "")
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	n := &sqlNode{}
+	have := n.makeDefinitionCreate(md, &oferrors.FirstBlock{})
+	for _, want := range []string{
+		"\tid VARCHAR(255) NOT NULL,\n",
+		"\tunits VARCHAR(255) NOT NULL DEFAULT 'usd',\n",
+		"\tcount INTEGER DEFAULT 5,\n",
+		"\tnote VARCHAR(255) DEFAULT 'it''s',\n",
+	} {
+		if !strings.Contains(have, want) {
+			t.Fatalf("Want %q in %v", want, have)
+		}
+	}
+}
+
 // ---------------------------------------------------------
 // TEST-DATA
 
@@ -75,4 +147,227 @@ var (
 			{Name: "Skip2", Tag: "-,"},
 		},
 	}
+
+	indexStruct = &pipeline.StructData{
+		Name: "Index",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "First", Type: "string", Tag: "index, index(a, 1)"},
+			{Name: "Second", Type: "int", Tag: "index(a, 0, unique)"},
+		},
+	}
+
+	uniqueStruct = &pipeline.StructData{
+		Name: "Unique",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "First", Type: "string", Tag: "unique(a)"},
+			{Name: "Second", Type: "int", Tag: "unique, unique(a)"},
+		},
+	}
+
+	typeStruct = &pipeline.StructData{
+		Name: "Type",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Text", Type: "string", Tag: "type(text)"},
+			{Name: "Sized", Type: "string", Tag: "size(16)"},
+			{Name: "Char", Type: "string", Tag: "type(CHAR), size(4)"},
+			{Name: "Big", Type: "int64", Tag: "type(BIGINT)"},
+			{Name: "Values", Type: "[]int", Tag: "type(BLOB)"},
+			{Name: "Small", Type: "int16"},
+		},
+	}
+
+	typeMismatchStruct = &pipeline.StructData{
+		Name: "TypeMismatch",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Count", Type: "int64", Tag: "type(TEXT)"},
+		},
+	}
+
+	sizeIntStruct = &pipeline.StructData{
+		Name: "SizeInt",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Count", Type: "int64", Tag: "size(4)"},
+		},
+	}
+
+	formatStruct = &pipeline.StructData{
+		Name: "Format",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Tags", Type: "[]string", Tag: "format(gob)"},
+			{Name: "When", Type: "time.Time", Tag: "format(text)"},
+			{Name: "Values", Type: "[]int"},
+			{Name: "Count", Type: "int64"},
+		},
+	}
+
+	formatMismatchStruct = &pipeline.StructData{
+		Name: "FormatMismatch",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Tags", Type: "[]string", Tag: "format(gob), type(INTEGER)"},
+		},
+	}
+
+	stampStruct = &pipeline.StructData{
+		Name: "Stamp",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Created", Type: pipeline.UnknownType, RawType: "time.Time", Tag: "created"},
+			{Name: "Updated", Type: "int64", RawType: "int64", Tag: "updated"},
+		},
+	}
+
+	stampStringStruct = &pipeline.StructData{
+		Name: "StampString",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Created", Type: "string", RawType: "string", Tag: "created"},
+		},
+	}
+
+	versionStruct = &pipeline.StructData{
+		Name: "Version",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Version", Type: "uint32", RawType: "uint32", Tag: "version"},
+		},
+	}
+
+	twoVersionStruct = &pipeline.StructData{
+		Name: "TwoVersion",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "A", Type: "int64", RawType: "int64", Tag: "version"},
+			{Name: "B", Type: "int64", RawType: "int64", Tag: "version"},
+		},
+	}
+
+	defaultStruct = &pipeline.StructData{
+		Name: "Default",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Units", Type: "string", Tag: "default(usd), notnull"},
+			{Name: "Count", Type: "int", Tag: "default(5), nullable"},
+			{Name: "Note", Type: "string", Tag: `default("it's")`},
+		},
+	}
+
+	defaultJsonStruct = &pipeline.StructData{
+		Name: "DefaultJson",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Values", Type: "[]int", Tag: "default(1)"},
+		},
+	}
+
+	nullableKeyStruct = &pipeline.StructData{
+		Name: "NullableKey",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key, nullable"},
+		},
+	}
+
+	autoincStruct = &pipeline.StructData{
+		Name: "Autoinc",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "uint64", Tag: "key, autoinc"},
+			{Name: "Name", Type: "string", Tag: "key(a)"},
+		},
+	}
+
+	autoincLocalStruct = &pipeline.StructData{
+		Name: "AutoincLocal",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "uint64", Tag: "key, autoinc(local)"},
+		},
+	}
+
+	autoincIndexStruct = &pipeline.StructData{
+		Name: "AutoincIndex",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Count", Type: "uint64", Tag: "key(a), autoinc"},
+		},
+	}
+
+	autoincStringStruct = &pipeline.StructData{
+		Name: "AutoincString",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key, autoinc"},
+		},
+	}
+
+	flattenStruct = &pipeline.StructData{
+		Name: "Flatten",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Home.City", Type: "string", RawType: "string", Tag: "flatten(home_)"},
+			{Name: "Home.Street", Type: "string", RawType: "string", Tag: "flatten(home_), name(st)"},
+		},
+	}
+
+	wasStruct = &pipeline.StructData{
+		Name: "Was",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Body", Type: "string", Tag: "was(text, content)"},
+		},
+	}
+
+	wasInUseStruct = &pipeline.StructData{
+		Name: "WasInUse",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Body", Type: "string", Tag: "was(note)"},
+			{Name: "Note", Type: "string"},
+		},
+	}
+
+	encryptStruct = &pipeline.StructData{
+		Name: "Encrypt",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Ssn", Type: "string", Tag: "encrypt"},
+		},
+	}
+
+	encryptKeyStruct = &pipeline.StructData{
+		Name: "EncryptKey",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key, encrypt"},
+		},
+	}
+
+	compressStruct = &pipeline.StructData{
+		Name: "Compress",
+		Fields: []pipeline.StructField{
+			{Name: "Id", Type: "string", Tag: "key"},
+			{Name: "Log", Type: "[]string", Tag: "format(gob), compress(gzip)"},
+			{Name: "Note", Type: "string", Tag: "compress(flate)"},
+		},
+	}
+
+	namingStruct = &pipeline.StructData{
+		Name: "NamingCase",
+		Fields: []pipeline.StructField{
+			{Name: "UserID", Type: "string", Tag: "key"},
+			{Name: "FoundedYear", Type: "int", Tag: "name(fy)"},
+		},
+	}
+
+	namingTableStruct = &pipeline.StructData{
+		Name: "NamingTable",
+		Fields: []pipeline.StructField{
+			{Name: "UserID", Type: "string", Tag: "key"},
+		},
+		UnexportedFields: []pipeline.StructField{
+			{Name: "_table", Tag: "name(companies), naming(snake_case)"},
+		},
+	}
 )
@@ -1,16 +1,21 @@
 package nodes
 
 import (
+	"cmp"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/hackborn/doc_drivers/enc"
 	oferrors "github.com/hackborn/onefunc/errors"
 	"github.com/hackborn/onefunc/pipeline"
 	ofstrings "github.com/hackborn/onefunc/strings"
 )
 
-func newSqlNode(tablePrefix string, dropTables bool) pipeline.Node {
+func newSqlNode(tablePrefix, naming string, dropTables bool) pipeline.Node {
 	n := &sqlNode{}
-	n.sqlNodeData = sqlNodeData{Format: FormatSqlite, TablePrefix: tablePrefix, DropTables: dropTables}
+	n.sqlNodeData = sqlNodeData{Format: FormatSqlite, TablePrefix: tablePrefix, Naming: naming, DropTables: dropTables}
 	// Make functions
 	n.makes = []makeSqlPinFunc{
 		n.makeDefinitionPin,
@@ -27,6 +32,7 @@ type sqlNode struct {
 type sqlNodeData struct {
 	Format      string
 	TablePrefix string
+	Naming      string
 	DropTables  bool
 }
 
@@ -56,7 +62,7 @@ func (n *sqlNode) Run(state *pipeline.State, input pipeline.RunInput, output *pi
 }
 
 func (n *sqlNode) makeDefinitionPin(data *sqlNodeData, state *pipeline.State, pin *pipeline.StructData) (pipeline.Pin, error) {
-	md, ok, err := makeMetadata(pin, data.TablePrefix)
+	md, ok, err := makeMetadata(pin, data.TablePrefix, data.Naming)
 	if !ok {
 		return pipeline.Pin{}, nil
 	}
@@ -80,18 +86,43 @@ func (n *sqlNode) makeDefinitionCols(md metadata, eb oferrors.Block) string {
 	sb.WriteString("\tcols: []{{.Prefix}}SqlTableCol{\n")
 	keys := md.KeySpecs()
 	for _, field := range md.Fields {
-		format := ""
+		format := field.Format
 		// Masks are defined in ref/ref_sql.go
-		mask := "0"
-		sqlType := convertGoTypeToSQLType(field.Type)
-		if field.Type == pipeline.UnknownType {
-			format = "json"
+		var masks []string
+		sqlType := field.DbType
+		// Autoinc has been validated as the integer primary key,
+		// which sqlite generates.
+		if field.Flags&enc.FlagAutoIncGlobal != 0 {
+			masks = append(masks, "colFlagAuto")
 		}
-		// In SQL, integer primary keys are always auto increment.
-		if sqlType == sqlInteger && keys.isPrimary(field.Tag) {
-			mask = "colFlagAuto"
+		if field.NotNull || keys.isPrimary(field.Tag) {
+			masks = append(masks, "colFlagNotNull")
 		}
-		sb.WriteString(fmt.Sprintf("\t\t{`%s`, `%s`, `%s`, %s},\n", field.Tag, sqlType, format, mask))
+		if field.Created {
+			masks = append(masks, "colFlagCreated")
+		}
+		if field.Updated {
+			masks = append(masks, "colFlagUpdated")
+		}
+		if field.Version {
+			masks = append(masks, "colFlagVersion")
+		}
+		if field.Encrypt {
+			masks = append(masks, "colFlagEncrypt")
+		}
+		switch field.Compress {
+		case enc.CompressGzip:
+			masks = append(masks, "colFlagGzip")
+		case enc.CompressFlate:
+			masks = append(masks, "colFlagFlate")
+		}
+		mask := cmp.Or(strings.Join(masks, " | "), "0")
+		def := strconv.Quote(sqlDefault(field))
+		was := "nil"
+		if len(field.Was) > 0 {
+			was = "[]string{`" + strings.Join(field.Was, "`, `") + "`}"
+		}
+		sb.WriteString(fmt.Sprintf("\t\t{`%s`, `%s`, `%s`, %s, %s, %s},\n", field.Tag, sqlType, format, mask, def, was))
 	}
 
 	sb.WriteString("\t},")
@@ -115,11 +146,14 @@ func (n *sqlNode) makeDefinitionCreate(md metadata, eb oferrors.Block) string {
 		if i > 0 {
 			sb.WriteString(",\n")
 		}
-		sqlType := convertGoTypeToSQLType(field.Type)
+		sqlType := field.DbType
 		postFix := ""
-		if keys.isPrimary(field.Tag) {
+		if field.NotNull || keys.isPrimary(field.Tag) {
 			postFix += " NOT NULL"
 		}
+		if def := sqlDefault(field); def != "" {
+			postFix += " DEFAULT " + def
+		}
 		sb.WriteString(fmt.Sprintf("\t%s %s%s", field.Tag, sqlType, postFix))
 	}
 
@@ -131,23 +165,132 @@ func (n *sqlNode) makeDefinitionCreate(md metadata, eb oferrors.Block) string {
 
 		if i == 0 {
 			sb.WriteString(",\n")
-			sb.WriteString("\tPRIMARY KEY (" + s + ")\n);\n")
+			sb.WriteString("\tPRIMARY KEY (" + s + ")")
+			// Unique constraints are named for their table, to
+			// match the indexes.
+			for _, u := range md.Uniques {
+				cols := ofstrings.CompileStrings(ca, u.columnNames()...)
+				sb.WriteString(fmt.Sprintf(",\n\tCONSTRAINT %v_%v UNIQUE (%v)", md.Name, u.Name, cols))
+			}
+			sb.WriteString("\n);\n")
 		} else if groupSpec.name != "" {
 			sb.WriteString(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %v ON %v (%v);\n", groupSpec.name, md.Name, s))
 		}
 	}
 
+	// Secondary indexes are named for their table, since
+	// sqlite index names are shared across the database.
+	for _, idx := range md.Indexes {
+		ca := ofstrings.CompileArgs{Separator: ","}
+		s := ofstrings.CompileStrings(ca, idx.columnNames()...)
+		create := "CREATE INDEX"
+		if idx.Unique {
+			create = "CREATE UNIQUE INDEX"
+		}
+		sb.WriteString(fmt.Sprintf("%v IF NOT EXISTS %v_%v ON %v (%v);\n", create, md.Name, idx.Name, md.Name, s))
+	}
+
 	sb.WriteString("`,")
 
 	return ofstrings.String(sb)
 }
 
+// sqlDefault answers the field default as an SQL literal,
+// or an empty string if there is no default.
+func sqlDefault(field structField) string {
+	switch v := field.Default.(type) {
+	case nil:
+		return ""
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// columnType answers the column type for a field of the Go type,
+// replaced or sized by the tag. The column must be able to store
+// the Go type without changing it. Formatted values are stored as
+// text for the text formats, otherwise as bytes. Encrypted and
+// compressed values are always stored as bytes.
+func columnType(goType, format string, pt enc.Tag) (string, error) {
+	dbType := convertGoTypeToSQLType(goType)
+	switch {
+	case pt.Encrypt, pt.Compress != "":
+		goType, dbType = pipeline.UnknownType, "BLOB"
+	case format == "":
+	case format == "json", format == "text":
+		goType, dbType = pipeline.UnknownType, "TEXT"
+	default:
+		goType, dbType = pipeline.UnknownType, "BLOB"
+	}
+	if pt.Type != "" {
+		dbType = strings.ToUpper(pt.Type)
+	}
+	if pt.Size > 0 {
+		switch {
+		case strings.Contains(pt.Type, "("):
+			return dbType, fmt.Errorf("Size can't be set on a sized type")
+		case pt.Type != "":
+			dbType = fmt.Sprintf("%v(%v)", dbType, pt.Size)
+		case goType == "string":
+			dbType = fmt.Sprintf("VARCHAR(%v)", pt.Size)
+		default:
+			return dbType, fmt.Errorf("Size requires a string field or a type")
+		}
+	}
+	if !slices.Contains(storableAffinities(goType), sqlAffinity(dbType)) {
+		stored := goType
+		if goType == pipeline.UnknownType {
+			stored = cmp.Or(format, "json")
+		}
+		return dbType, fmt.Errorf("Type %v can't store %v", dbType, stored)
+	}
+	return dbType, nil
+}
+
+// sqlAffinity answers the sqlite type affinity of the column
+// type, following the rules at https://sqlite.org/datatype3.html
+func sqlAffinity(dbType string) string {
+	switch {
+	case strings.Contains(dbType, "INT"):
+		return sqlInteger
+	case strings.Contains(dbType, "CHAR"), strings.Contains(dbType, "CLOB"), strings.Contains(dbType, "TEXT"):
+		return "TEXT"
+	case strings.Contains(dbType, "BLOB"), dbType == "":
+		return "BLOB"
+	case strings.Contains(dbType, "REAL"), strings.Contains(dbType, "FLOA"), strings.Contains(dbType, "DOUB"):
+		return "REAL"
+	default:
+		return "NUMERIC"
+	}
+}
+
+// storableAffinities answers the affinities that store the
+// primitive Go type as it's written.
+func storableAffinities(goType string) []string {
+	switch goType {
+	case "bool", "int", "int8", "uint8", "int16", "uint16", "int32", "uint32", "int64", "uint64":
+		return []string{sqlInteger, "NUMERIC"}
+	case "float", "float32", "float64":
+		return []string{"REAL", "NUMERIC"}
+	default:
+		// Strings, and values formatted as strings.
+		return []string{"TEXT", "BLOB"}
+	}
+}
+
 // convertGoTypeToSQLType converts a Go type to an SQL data type.
 func convertGoTypeToSQLType(goType string) string {
 	switch goType {
 	case "string":
 		return "VARCHAR(255)"
-	case "int", "int8", "uint8", "int32", "uint32", "int64", "uint64":
+	case "int", "int8", "uint8", "int16", "uint16", "int32", "uint32", "int64", "uint64":
 		return sqlInteger
 	case "float", "float32", "float64":
 		return "FLOAT"
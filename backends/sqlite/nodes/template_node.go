@@ -126,7 +126,6 @@ func (n *templateNode) processAutogenerated(c *makeTemplateContent) error {
 		c.Lines = slices.Insert(c.Lines, 1, line0, line1, line2)
 	}
 	c.nodeData.mapped[templateUtilPackageKey] = ""
-	c.nodeData.mapped[templateDatestampKey] = ""
 	return nil
 }
 
@@ -0,0 +1,37 @@
+package sqliterefdriver
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hackborn/doc_drivers/driverkit"
+)
+
+// ---------------------------------------------------------
+// TEST-SEAL
+func TestSeal(t *testing.T) {
+	keys := driverkit.StaticKey(bytes.Repeat([]byte{1}, 32))
+	sealed, err := _refSeal(keys, "contact", "ssn", []byte("123"))
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	f := func(table, column string, was []string, wantErr bool) {
+		t.Helper()
+
+		have, err := _refOpen(keys, table, column, was, sealed)
+		switch {
+		case wantErr && err == nil:
+			t.Fatalf("Opened %v.%v but wants an err", table, column)
+		case !wantErr && err != nil:
+			t.Fatalf("Has err %v", err)
+		case !wantErr && string(have) != "123":
+			t.Fatalf("Has %q but wants 123", have)
+		}
+	}
+	f("contact", "ssn", nil, false)
+	// The value is bound to its table and column.
+	f("contact", "phone", nil, true)
+	f("company", "ssn", nil, true)
+	// Values sealed before a rename open with the previous names.
+	f("contact", "tax_id", []string{"id", "ssn"}, false)
+}
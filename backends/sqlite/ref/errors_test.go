@@ -0,0 +1,41 @@
+package sqliterefdriver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hackborn/doc_drivers/driverkit"
+	"github.com/hackborn/onefunc/jacl"
+)
+
+// ---------------------------------------------------------
+// TEST-UNIQUE-ERROR
+func TestUniqueError(t *testing.T) {
+	f := func(msg string, want ...string) {
+		t.Helper()
+
+		dbErr := errors.New(msg)
+		have := _refUniqueError(dbErr)
+		var ue *driverkit.UniqueError
+		if !errors.Is(have, driverkit.ErrUnique) || !errors.As(have, &ue) {
+			t.Fatalf("Has err %v but wants a unique error", have)
+		}
+		if !errors.Is(have, dbErr) {
+			t.Fatalf("Has err %v but wants it to wrap %v", have, dbErr)
+		}
+		if err := jacl.Run(ue, want...); err != nil {
+			t.Fatalf("Has %v (%v)", ue, err)
+		}
+	}
+	f(`constraint failed: UNIQUE constraint failed: company.ticker (2067)`, `Table=company`, `Columns/{count}=1`, `Columns/0=ticker`)
+	f(`UNIQUE constraint failed: filing.ticker, filing.fy`, `Table=filing`, `Columns/{count}=2`, `Columns/0=ticker`, `Columns/1=fy`)
+
+	// Other errors are answered unchanged.
+	other := errors.New("NOT NULL constraint failed: company.name")
+	if have := _refUniqueError(other); have != other {
+		t.Fatalf("Has err %v but wants %v", have, other)
+	}
+	if have := _refUniqueError(nil); have != nil {
+		t.Fatalf("Has err %v but wants nil", have)
+	}
+}
@@ -1,9 +0,0 @@
-package sqliterefdriver
-
-import (
-	"github.com/hackborn/doc"
-)
-
-func NewDriver(name string) doc.Driver {
-	return &_refDriver{sqlDriverName: name}
-}
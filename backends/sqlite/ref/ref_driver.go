@@ -4,8 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hackborn/doc"
+	"github.com/hackborn/doc_drivers/driverkit"
 	"github.com/hackborn/onefunc/errors"
 	oferrors "github.com/hackborn/onefunc/errors"
 	"github.com/hackborn/onefunc/reflect"
@@ -16,6 +18,8 @@ type _refDriver struct {
 	db            *sql.DB
 	sqlDriverName string
 	format        doc.Format
+	// keys seal the encrypted fields, and can be nil.
+	keys driverkit.KeyProvider
 }
 
 func (d *_refDriver) Open(dataSourceName string) (doc.Driver, error) {
@@ -27,7 +31,7 @@ func (d *_refDriver) Open(dataSourceName string) (doc.Driver, error) {
 		return nil, eb.Err
 	}
 	f := doc.FormatWithDefaults(_refNewFormat())
-	return &_refDriver{db: db, format: f}, nil
+	return &_refDriver{db: db, format: f, keys: d.keys}, nil
 }
 
 func (d *_refDriver) Close() error {
@@ -44,34 +48,68 @@ func (d *_refDriver) Format() doc.Format {
 }
 
 func (d *_refDriver) Set(req doc.SetRequestAny, a doc.Allocator) (*doc.Optional, error) {
-	meta, keys, cols, err := d.prepareSet(a)
+	meta, keys, cols, err := d.prepareWrite(a)
 	if err != nil {
 		return nil, err
 	}
 
 	eb := &errors.FirstBlock{}
-	statement := _refSetSql
-	handler := &fieldsAndValuesHandler{cols: cols, filter: req.GetFilter()}
-	ca1 := ofstrings.CompileArgs{Quote: "", Separator: ", ", Eb: eb}
-	ca2 := ofstrings.CompileArgs{Quote: _refQuoteSz, Separator: ", ", Eb: eb}
-	reflect.Get(req.ItemAny(), reflect.NewChain(meta.FieldsToTags(), handler))
-	s := strings.ReplaceAll(statement, _refFieldsVar, ofstrings.Compile(ca1, handler.fields...))
-	s = strings.ReplaceAll(s, _refValuesVar, ofstrings.Compile(ca2, handler.values...))
-	s = strings.ReplaceAll(s, _refFieldValuesVar, makeExcludedFieldValues(eb, handler.fields))
+	handler := &fieldsAndValuesHandler{cols: cols, filter: req.GetFilter(), now: time.Now().UTC(), keys: d.keys, table: meta.table}
+	ca := ofstrings.CompileArgs{Quote: "", Separator: ", ", Eb: eb}
+	_refGetFields(req.ItemAny(), meta.FieldsToTags(), handler)
+	statement, args := _refSetSql, handler.values
+	version := handler.version
+	if version != nil {
+		// Only version 0 can insert, every other
+		// version must update the stored row.
+		statement = _refSetVersionSql
+		if version.value != 0 {
+			statement, args = _refUpdateVersionSql, handler.updateArgs(keys.tags)
+			keyNames := make([]any, 0, len(keys.tags))
+			for _, tag := range keys.tags {
+				keyNames = append(keyNames, tag)
+			}
+			statement = strings.ReplaceAll(statement, _refKeyValuesVar, makeKeyValues(eb, keyNames))
+		}
+	}
+	s := strings.ReplaceAll(statement, _refFieldsVar, ofstrings.Compile(ca, handler.fields...))
+	s = strings.ReplaceAll(s, _refValuesVar, makePlaceholders(eb, len(handler.values)))
+	s = strings.ReplaceAll(s, _refFieldValuesVar, makeExcludedFieldValues(eb, handler.updates))
+	s = strings.ReplaceAll(s, _refAssignsVar, makeAssignments(eb, handler.updates))
 	s = strings.ReplaceAll(s, _refTableVar, meta.table)
-	s = strings.ReplaceAll(s, _refKeysVar, ofstrings.CompileStrings(ca1, keys.tags...))
+	s = strings.ReplaceAll(s, _refKeysVar, ofstrings.CompileStrings(ca, keys.tags...))
+	if version != nil {
+		s = strings.ReplaceAll(s, _refVersionVar, version.name)
+	}
+	eb.AddError(handler.err)
 	if eb.Err != nil {
 		return nil, eb.Err
 	}
 
-	//	fmt.Println("EXEC", s)
-	if _, err := d.db.Exec(s); err != nil {
-		return nil, err
+	//	fmt.Println("EXEC", s, args)
+	result, err := d.db.Exec(s, args...)
+	if err != nil {
+		return nil, _refUniqueError(err)
+	}
+	if version != nil {
+		// Nothing is written when the version doesn't match.
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n < 1 {
+			return nil, &driverkit.ConflictError{Table: meta.table, Version: version.value}
+		}
+		// The response has the stored version, so it can be set again.
+		field := meta.TagsToFields()[version.name]
+		if err := _refNextVersion(a.New(), req.ItemAny(), field, version.value+1); err != nil {
+			return nil, err
+		}
 	}
 	return nil, nil
 }
 
-func (d *_refDriver) prepareSet(a doc.Allocator) (*_refMetadata, *_refKeyMetadata, []_refSqlTableCol, error) {
+func (d *_refDriver) prepareWrite(a doc.Allocator) (*_refMetadata, *_refKeyMetadata, []_refSqlTableCol, error) {
 	tn := a.TypeName()
 	meta, ok := _refMetadatas[tn]
 	if !ok {
@@ -96,7 +134,7 @@ func (d *_refDriver) Get(req doc.GetRequest, a doc.Allocator) (*doc.Optional, er
 	eb := &errors.FirstBlock{}
 	ca := ofstrings.CompileArgs{Quote: "", Separator: ", ", Eb: eb}
 	selectFields := ofstrings.CompileStrings(ca, tags...)
-	where, err := whereClause(req)
+	where, args, err := whereClause(req)
 	if eb.Err != nil {
 		return nil, eb.Err
 	}
@@ -108,8 +146,8 @@ func (d *_refDriver) Get(req doc.GetRequest, a doc.Allocator) (*doc.Optional, er
 		s += "DISTINCT "
 	}
 	s += selectFields + " FROM " + meta.table + where + ";"
-	// fmt.Println("QUERY 1", s)
-	rows, err := d.db.Query(s)
+	// fmt.Println("QUERY 1", s, args)
+	rows, err := d.db.Query(s, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -119,17 +157,23 @@ func (d *_refDriver) Get(req doc.GetRequest, a doc.Allocator) (*doc.Optional, er
 	for i := range dest {
 		dest[i] = new(any)
 	}
+	values := make([]any, fieldCount)
 
-	vreq := reflect.SetRequest{
+	vreq := _refSetFields(reflect.SetRequest{
 		FieldNames: fields,
-		NewValues:  dest,
+		NewValues:  values,
 		Assigns:    assigns,
-	}
+	})
 
 	for rows.Next() {
 		if err = rows.Scan(dest...); err != nil {
 			return nil, err
 		}
+		// NULL values are nil, which are skipped,
+		// leaving the field at its zero value.
+		for i, v := range dest {
+			values[i] = *v.(*any)
+		}
 		resp := a.New()
 		if err = reflect.Set(vreq, resp); err != nil {
 			return nil, err
@@ -152,40 +196,29 @@ func (d *_refDriver) prepareGet(req doc.GetRequest, a doc.Allocator) (*_refMetad
 	if !ok {
 		return nil, nil, nil, nil, fmt.Errorf("missing tabledef for \"%v\"", tn)
 	}
-	assigns := tableDef.AssignsFor(tags)
+	assigns := tableDef.AssignsFor(tags, d.keys, meta.table)
 	return meta, tags, fields, assigns, nil
 }
 
 func (d *_refDriver) Delete(req doc.DeleteRequestAny, a doc.Allocator) (*doc.Optional, error) {
-	meta, ok := _refMetadatas[a.TypeName()]
-	if !ok {
-		return nil, fmt.Errorf("missing metadata for \"%v\"", a.TypeName())
-	}
-	keys, ok := meta.keys[""]
-	if !ok {
-		return nil, fmt.Errorf("missing primary key metadata for \"%v\"", a.TypeName())
-	}
-
-	opts := reflect.SliceOpts{Assign: doc.AssignKeyword, Combine: doc.AndKeyword}
-	exprSlice := reflect.GetAsSlice(req.ItemAny(), reflect.NewChain(keys.FieldsToTags()), &opts)
-	expr := ""
-	dexpr, err := doc.NewExpr(d.format, exprSlice...)
+	meta, keys, cols, err := d.prepareWrite(a)
 	if err != nil {
 		return nil, err
-	} else {
-		s, err := dexpr.Format()
-		if err != nil {
-			return nil, err
-		}
-		expr = s
 	}
 
+	eb := &errors.FirstBlock{}
+	handler := &fieldsAndValuesHandler{cols: cols}
+	_refGetFields(req.ItemAny(), keys.FieldsToTags(), handler)
 	s := _refDelSql
 	s = strings.ReplaceAll(s, _refTableVar, meta.table)
-	s = strings.ReplaceAll(s, _refKeyValuesVar, expr)
-	// fmt.Println("delete statemet", s)
+	s = strings.ReplaceAll(s, _refKeyValuesVar, makeKeyValues(eb, handler.fields))
+	eb.AddError(handler.err)
+	if eb.Err != nil {
+		return nil, eb.Err
+	}
+	// fmt.Println("delete statemet", s, handler.values)
 
-	if _, err := d.db.Exec(s); err != nil {
+	if _, err := d.db.Exec(s, handler.values...); err != nil {
 		return nil, err
 	}
 	return nil, nil
@@ -0,0 +1,37 @@
+package sqliterefdriver
+
+import (
+	"strings"
+
+	"github.com/hackborn/doc_drivers/driverkit"
+)
+
+// _refUniqueError answers a driverkit.UniqueError if err is a unique
+// constraint failure, otherwise err. Sqlite reports the
+// failed columns in the message, as "table.column, ...".
+func _refUniqueError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	idx := strings.Index(msg, _refUniqueFailed)
+	if idx < 0 {
+		return err
+	}
+	msg = msg[idx+len(_refUniqueFailed):]
+	// Drop any extended error code.
+	if end := strings.Index(msg, " ("); end >= 0 {
+		msg = msg[:end]
+	}
+	ue := &driverkit.UniqueError{Err: err}
+	for _, col := range strings.Split(msg, ", ") {
+		table, name, _ := strings.Cut(col, ".")
+		ue.Table = table
+		ue.Columns = append(ue.Columns, name)
+	}
+	return ue
+}
+
+const (
+	_refUniqueFailed = "UNIQUE constraint failed: "
+)
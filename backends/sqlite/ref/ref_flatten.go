@@ -0,0 +1,112 @@
+package sqliterefdriver
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	ofreflect "github.com/hackborn/onefunc/reflect"
+)
+
+// _refGetFields calls h with the tag and value of every field
+// in fieldsToTags, the same as reflect.Get with the map in the
+// chain, except that the fields of flattened structs are
+// found by their path, i.e. "Address.City".
+func _refGetFields(item any, fieldsToTags map[string]string, h ofreflect.GetHandler) {
+	chain := ofreflect.NewChain(fieldsToTags, h)
+	parents := _refFieldParents(fieldsToTags)
+	if len(parents) < 1 {
+		ofreflect.Get(item, chain)
+		return
+	}
+	_refGetStruct(reflect.Indirect(reflect.ValueOf(item)), "", parents, chain)
+}
+
+func _refGetStruct(v reflect.Value, prefix string, parents map[string]bool, h ofreflect.GetHandler) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanInterface() {
+			continue
+		}
+		name := prefix + t.Field(i).Name
+		if parents[name] && f.Kind() == reflect.Struct {
+			_refGetStruct(f, name+".", parents, h)
+			continue
+		}
+		h.Handle(name, f.Interface())
+	}
+}
+
+// _refFieldParents answers the path of every flattened
+// struct in the field names.
+func _refFieldParents(fieldsToTags map[string]string) map[string]bool {
+	var parents map[string]bool
+	for field := range fieldsToTags {
+		for i, r := range field {
+			if r != '.' {
+				continue
+			}
+			if parents == nil {
+				parents = make(map[string]bool)
+			}
+			parents[field[:i]] = true
+		}
+	}
+	return parents
+}
+
+// _refSetFields answers the request with the fields of
+// flattened structs, which reflect.Set can't find by path,
+// set through the top-level field that contains them.
+func _refSetFields(req ofreflect.SetRequest) ofreflect.SetRequest {
+	if !slices.ContainsFunc(req.FieldNames, func(name string) bool {
+		return strings.Contains(name, ".")
+	}) {
+		return req
+	}
+	names := slices.Clone(req.FieldNames)
+	assigns := make([]ofreflect.SetFunc, len(names))
+	copy(assigns, req.Assigns)
+	for i, name := range names {
+		if top, path, ok := strings.Cut(name, "."); ok {
+			names[i] = top
+			assigns[i] = _refPathSetFunc(path, assigns[i], req.Flags)
+		}
+	}
+	req.FieldNames, req.Assigns = names, assigns
+	return req
+}
+
+// _refPathSetFunc answers a function that sets the field at
+// path inside dst, using assign if it isn't nil.
+func _refPathSetFunc(path string, assign ofreflect.SetFunc, flags uint8) ofreflect.SetFunc {
+	return func(src, dst reflect.Value) error {
+		name := path
+		if i := strings.LastIndex(path, "."); i >= 0 {
+			dst, name = _refFieldByPath(dst, path[:i]), path[i+1:]
+		}
+		if !dst.IsValid() || !dst.CanAddr() {
+			return fmt.Errorf("no field for %v", path)
+		}
+		req := ofreflect.SetRequest{FieldNames: []string{name},
+			NewValues: []any{src.Interface()},
+			Assigns:   []ofreflect.SetFunc{assign},
+			Flags:     flags,
+		}
+		return ofreflect.Set(req, dst.Addr().Interface())
+	}
+}
+
+// _refFieldByPath answers the field at the path, i.e.
+// "Address.City", or an invalid value if there is none.
+func _refFieldByPath(v reflect.Value, path string) reflect.Value {
+	for _, name := range strings.Split(path, ".") {
+		v = v.FieldByName(name)
+		if !v.IsValid() {
+			return v
+		}
+	}
+	return v
+}
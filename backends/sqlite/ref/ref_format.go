@@ -0,0 +1,39 @@
+package sqliterefdriver
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hackborn/doc_drivers/driverkit"
+	ofreflect "github.com/hackborn/onefunc/reflect"
+)
+
+// _refFormatSetFunc answers a function that sets the
+// stored value into the field using the named format.
+// Decode, if not nil, is applied to the stored bytes first.
+func _refFormatSetFunc(name string, decode func([]byte) ([]byte, error)) ofreflect.SetFunc {
+	return func(src, dst reflect.Value) error {
+		f, err := driverkit.FindFormat(name)
+		if err != nil {
+			return err
+		}
+		var data []byte
+		switch {
+		case src.Kind() == reflect.String:
+			data = []byte(src.String())
+		case src.Kind() == reflect.Slice && src.Type().Elem().Kind() == reflect.Uint8:
+			data = src.Bytes()
+		default:
+			return fmt.Errorf("Format \"%v\" requires a string or bytes source value", name)
+		}
+		if decode != nil {
+			if data, err = decode(data); err != nil {
+				return err
+			}
+		}
+		val := reflect.New(dst.Type())
+		err = f.Unmarshal(data, val.Interface())
+		dst.Set(val.Elem())
+		return err
+	}
+}
@@ -0,0 +1,16 @@
+package sqliterefdriver
+
+import (
+	"github.com/hackborn/doc"
+	"github.com/hackborn/doc_drivers/driverkit"
+)
+
+func NewDriver(name string) doc.Driver {
+	return &_refDriver{sqlDriverName: name}
+}
+
+// NewDriverWithKeys answers a driver that seals the fields
+// tagged encrypt with keys from the provider.
+func NewDriverWithKeys(name string, keys driverkit.KeyProvider) doc.Driver {
+	return &_refDriver{sqlDriverName: name, keys: keys}
+}
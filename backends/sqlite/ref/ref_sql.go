@@ -4,8 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 
+	"github.com/hackborn/doc_drivers/driverkit"
 	oferrors "github.com/hackborn/onefunc/errors"
 	ofreflect "github.com/hackborn/onefunc/reflect"
 )
@@ -25,30 +27,22 @@ func (d *_refSqlTableDef) Col(name string) (_refSqlTableCol, bool) {
 	return _refSqlTableCol{}, false
 }
 
-func (d *_refSqlTableDef) AssignsFor(tags []string) []ofreflect.SetFunc {
+// AssignsFor answers the functions that set the stored values
+// into the fields. Encrypted columns are opened with the keys
+// for the table, and compressed columns are decompressed.
+func (d *_refSqlTableDef) AssignsFor(tags []string, keys driverkit.KeyProvider, table string) []ofreflect.SetFunc {
 	ans := make([]ofreflect.SetFunc, 0, len(tags))
 	for _, tag := range tags {
-		ans = append(ans, d.assignForTag(tag, d.cols))
+		ans = append(ans, d.assignForTag(tag, keys, table))
 	}
 	return ans
 }
 
-func (d *_refSqlTableDef) assignForTag(tag string, cols []_refSqlTableCol) ofreflect.SetFunc {
-	switch d.formatForTag(tag, cols) {
-	case "json":
-		return ofreflect.SetJson
-	default:
-		return nil
+func (d *_refSqlTableDef) assignForTag(tag string, keys driverkit.KeyProvider, table string) ofreflect.SetFunc {
+	if col, ok := d.Col(tag); ok && col.format != "" {
+		return _refFormatSetFunc(col.format, col.decodeFunc(keys, table))
 	}
-}
-
-func (d *_refSqlTableDef) formatForTag(tag string, cols []_refSqlTableCol) string {
-	for _, col := range cols {
-		if col.name == tag {
-			return col.format
-		}
-	}
-	return ""
+	return nil
 }
 
 type _refSqlTableCol struct {
@@ -61,16 +55,92 @@ type _refSqlTableCol struct {
 	// format optionally specifies a serialization format for storing this
 	// field in the database. For example, if the Go type can't be translated
 	// to a type in the database, this can be specified to "json" to write
-	// the value to JSON and store it as a string.
+	// the value to JSON and store it as a string. See driverkit.RegisterFormat().
 	format string
 
 	// Additional info about this column.
 	flags uint64
+
+	// def is the column default as an SQL literal, if any.
+	def string
+
+	// was are the names the column had before it was renamed.
+	was []string
+}
+
+// addDefault answers the default used when adding the column
+// to an existing table. Sqlite can't add a NOT NULL column
+// without a default, so those get the zero value for the type.
+func (c _refSqlTableCol) addDefault() string {
+	if c.def != "" || c.flags&colFlagNotNull == 0 {
+		return c.def
+	}
+	switch c.dbType {
+	case "INTEGER", "FLOAT", "BOOLEAN":
+		return "0"
+	default:
+		return "''"
+	}
+}
+
+// compression answers the algorithm that compresses
+// the column, or an empty string.
+func (c _refSqlTableCol) compression() string {
+	switch {
+	case c.flags&colFlagGzip != 0:
+		return "gzip"
+	case c.flags&colFlagFlate != 0:
+		return "flate"
+	}
+	return ""
+}
+
+// encode compresses and then seals the formatted value,
+// as the column requires. Sealed data doesn't compress.
+func (c _refSqlTableCol) encode(data []byte, keys driverkit.KeyProvider, table string) ([]byte, error) {
+	var err error
+	if compression := c.compression(); compression != "" {
+		if data, err = _refCompress(compression, data); err != nil {
+			return nil, err
+		}
+	}
+	if c.flags&colFlagEncrypt != 0 {
+		return _refSeal(keys, table, c.name, data)
+	}
+	return data, nil
+}
+
+// decodeFunc answers a function that reverses encode,
+// or nil if the column is only formatted.
+func (c _refSqlTableCol) decodeFunc(keys driverkit.KeyProvider, table string) func([]byte) ([]byte, error) {
+	encrypted, compression, name, was := c.flags&colFlagEncrypt != 0, c.compression(), c.name, c.was
+	if !encrypted && compression == "" {
+		return nil
+	}
+	return func(data []byte) ([]byte, error) {
+		var err error
+		if encrypted {
+			if data, err = _refOpen(keys, table, name, was, data); err != nil {
+				return nil, err
+			}
+		}
+		if compression != "" {
+			return _refDecompress(compression, data)
+		}
+		return data, nil
+	}
 }
 
 const (
 	// NOTE: Flag names are referenced in nodes/sql_node.go
-	colFlagAuto = 1 << iota // The column value is auto-generated.
+	colFlagAuto    = 1 << iota // The column value is auto-generated.
+	colFlagNotNull             // The column doesn't accept NULL.
+	colFlagCreated             // The column is set to the time the row is inserted.
+	colFlagUpdated             // The column is set to the time of every write.
+	colFlagVersion             // The column is incremented on every write.
+	colFlagEncrypt             // The column is sealed with the table key.
+	colFlagGzip                // The column is compressed with gzip.
+	colFlagFlate               // The column is compressed with flate.
 )
 
 // _refRawSqlTable is a representation of an existing SQL table.
@@ -82,6 +152,12 @@ type _refRawSqlTable struct {
 
 func _refSqlSyncTable(db *sql.DB, name string, meta *_refMetadata, eb oferrors.Block) {
 	constTable := _refTableDefs[name]
+	// Renames come first, so the create statement
+	// finds the columns under their new names.
+	_refSqlRenameCols(db, constTable, meta, eb)
+	if eb.HasError() {
+		return
+	}
 	// Always try and create it. This is important for testing, which wants
 	// to construct the table each time
 	_, err := db.Exec(constTable.create)
@@ -96,7 +172,14 @@ func _refSqlSyncTable(db *sql.DB, name string, meta *_refMetadata, eb oferrors.B
 	for _, constcol := range constTable.cols {
 		if sqlcol, ok := sqlTable.Col(constcol.name); !ok {
 			// Add the field
-			stmt := `ALTER TABLE ` + meta.table + ` ADD COLUMN ` + constcol.name + ` ` + constcol.dbType + `;`
+			stmt := `ALTER TABLE ` + meta.table + ` ADD COLUMN ` + constcol.name + ` ` + constcol.dbType
+			if constcol.flags&colFlagNotNull != 0 {
+				stmt += ` NOT NULL`
+			}
+			if def := constcol.addDefault(); def != "" {
+				stmt += ` DEFAULT ` + def
+			}
+			stmt += `;`
 			_, err := db.Exec(stmt)
 			eb.AddError(err)
 		} else if constcol.dbType != sqlcol.dbType {
@@ -105,6 +188,34 @@ func _refSqlSyncTable(db *sql.DB, name string, meta *_refMetadata, eb oferrors.B
 	}
 }
 
+// _refSqlRenameCols renames the columns that are stored
+// under a previous name, keeping their data.
+func _refSqlRenameCols(db *sql.DB, constTable _refSqlTableDef, meta *_refMetadata, eb oferrors.Block) {
+	if !slices.ContainsFunc(constTable.cols, func(col _refSqlTableCol) bool {
+		return len(col.was) > 0
+	}) {
+		return
+	}
+	// The table is empty if it doesn't exist yet.
+	sqlTable := _refNewSqlTable(db, meta.table, eb)
+	if eb.HasError() {
+		return
+	}
+	for _, constcol := range constTable.cols {
+		if _, ok := sqlTable.Col(constcol.name); ok {
+			continue
+		}
+		for _, was := range constcol.was {
+			if _, ok := sqlTable.Col(was); ok {
+				stmt := `ALTER TABLE ` + meta.table + ` RENAME COLUMN ` + was + ` TO ` + constcol.name + `;`
+				_, err := db.Exec(stmt)
+				eb.AddError(err)
+				break
+			}
+		}
+	}
+}
+
 func _refNewSqlTable(db *sql.DB, tablename string, eb oferrors.Block) _refSqlTableDef {
 	// SQLite describe table
 	stmt := `pragma table_info('` + tablename + `');`
@@ -158,13 +269,14 @@ func _refNewSqlTableFromRaw(rows *sql.Rows, raw _refRawSqlTable, eb oferrors.Blo
 		dest[i] = new(any)
 		// Not sure how much variation there is between SQL
 		// implementations but I've seen at least these.
+		// A missing table has no rows, so the types are unknown.
 		if lc == "name" || lc == "field" {
-			if raw.Types[i].Kind() != reflect.String {
+			if raw.Types[i] != nil && raw.Types[i].Kind() != reflect.String {
 				eb.AddError(fmt.Errorf("name column is not string"))
 			}
 			dest[i] = &dbName
 		} else if lc == "type" {
-			if raw.Types[i].Kind() != reflect.String {
+			if raw.Types[i] != nil && raw.Types[i].Kind() != reflect.String {
 				eb.AddError(fmt.Errorf("type column is not string"))
 			}
 			dest[i] = &dbType
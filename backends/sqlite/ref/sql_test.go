@@ -0,0 +1,62 @@
+package sqliterefdriver
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/hackborn/onefunc/errors"
+	_ "modernc.org/sqlite"
+)
+
+// ---------------------------------------------------------
+// TEST-SYNC-RENAME
+func TestSyncRename(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	defer db.Close()
+	// The table from before the rename, with a row.
+	_, err = db.Exec(`CREATE TABLE wasitem (id VARCHAR(255) NOT NULL, text VARCHAR(255), PRIMARY KEY (id));
+INSERT INTO wasitem (id, text) VALUES ('a', 'hello');`)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+
+	// The table isn't dropped, as with droptables=false.
+	_refTableDefs["WasItem"] = _refSqlTableDef{
+		cols: []_refSqlTableCol{
+			{`id`, `VARCHAR(255)`, ``, colFlagNotNull, "", nil},
+			{`body`, `VARCHAR(255)`, ``, 0, "", []string{`content`, `text`}},
+		},
+		create: `CREATE TABLE IF NOT EXISTS wasitem (
+	id VARCHAR(255) NOT NULL,
+	body VARCHAR(255),
+	PRIMARY KEY (id)
+);
+`,
+	}
+	defer delete(_refTableDefs, "WasItem")
+	meta := &_refMetadata{table: "wasitem"}
+	f := func() {
+		t.Helper()
+
+		eb := &errors.FirstBlock{}
+		_refSqlSyncTable(db, "WasItem", meta, eb)
+		if eb.Err != nil {
+			t.Fatalf("Has err %v", eb.Err)
+		}
+		var body string
+		err := db.QueryRow(`SELECT body FROM wasitem WHERE id = 'a';`).Scan(&body)
+		if err != nil {
+			t.Fatalf("Has err %v", err)
+		}
+		if body != "hello" {
+			t.Fatalf("Has body %v but wants hello", body)
+		}
+	}
+	f()
+	// Syncing again finds the column under its current name.
+	f()
+}
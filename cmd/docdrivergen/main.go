@@ -0,0 +1,139 @@
+// Command docdrivergen generates a doc driver from a collection
+// of domain classes. It is the non-interactive counterpart to
+// driverutil, intended for go:generate and CI:
+//
+//	//go:generate go run github.com/hackborn/doc_drivers/cmd/docdrivergen -format sqlite -load ./domain/* -save ./driver -pkg driver -prefix gen
+//
+// The lint subcommand checks the domain classes without generating
+// anything. Without a -format or config, every backend is checked.
+// It exits with a non-zero code if there are any errors:
+//
+//	docdrivergen lint -load ./domain/*
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	drivers "github.com/hackborn/doc_drivers"
+)
+
+func main() {
+	args, lint := os.Args[1:], false
+	if len(args) > 0 && args[0] == "lint" {
+		args, lint = args[1:], true
+	}
+	settings, err := parseSettings(args, lint)
+	if err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		fail(err)
+	}
+	if lint {
+		fail(runLint(settings))
+		return
+	}
+	fail(drivers.MakeDriver(settings))
+}
+
+// runLint prints every finding, answering an error
+// if any of them are errors.
+func runLint(settings drivers.MakeDriverSettings) error {
+	findings, err := drivers.Lint(settings)
+	if err != nil {
+		return err
+	}
+	errs := 0
+	for _, f := range findings {
+		fmt.Println(f)
+		if f.Severity == drivers.LintError {
+			errs++
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("lint found %v errors", errs)
+	}
+	return nil
+}
+
+// parseSettings converts the command line args into driver settings.
+// Lint only needs the domain, so it doesn't require the output flags.
+func parseSettings(args []string, lint bool) (drivers.MakeDriverSettings, error) {
+	s := drivers.MakeDriverSettings{}
+	flags, include, exclude := "", "", ""
+	fs := flag.NewFlagSet("docdrivergen", flag.ContinueOnError)
+	fs.StringVar(&s.ConfigPath, "config", "", "path to a JSON config file, used for any flags that aren't set")
+	fs.StringVar(&s.Format, "format", "", "storage format of the driver (sqlite, bbolt), default sqlite")
+	fs.StringVar(&s.LoadGlob, "load", "", "glob to the domain classes used to generate the driver")
+	fs.StringVar(&s.LoadSeparator, "loadsep", "", "optional separator used to split -load into multiple globs")
+	fs.StringVar(&s.SavePath, "save", "", "folder where the driver is saved")
+	fs.StringVar(&s.Pkg, "pkg", "", "package name of the driver")
+	fs.StringVar(&s.Prefix, "prefix", "", "prefix for the driver types")
+	fs.StringVar(&flags, "flags", "", "comma-separated list of per-driver flags")
+	fs.StringVar(&s.Naming, "naming", "", "naming for untagged tables and columns (snake_case, lower, camel, verbatim)")
+	fs.StringVar(&include, "include", "", "comma-separated list of struct names or regexes to include")
+	fs.StringVar(&exclude, "exclude", "", "comma-separated list of struct names or regexes to exclude")
+	fs.BoolVar(&s.InputHash, "hash", false, "add a hash of the inputs to the generated file headers")
+	fs.BoolVar(&s.Check, "check", false, "report files that differ from the driver in -save without writing anything")
+	if err := fs.Parse(args); err != nil {
+		return s, err
+	}
+	if fs.NArg() > 0 {
+		return s, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	s.Flags = splitList(flags)
+	s.Include = splitList(include)
+	s.Exclude = splitList(exclude)
+	// Everything else can come from the config.
+	if s.ConfigPath != "" {
+		return s, nil
+	}
+	if lint {
+		if s.LoadGlob == "" {
+			return s, fmt.Errorf("missing required flags -load")
+		}
+		return s, nil
+	}
+	if s.Format == "" {
+		s.Format = "sqlite"
+	}
+	missing := []string{}
+	if s.LoadGlob == "" {
+		missing = append(missing, "-load")
+	}
+	if s.SavePath == "" {
+		missing = append(missing, "-save")
+	}
+	if s.Pkg == "" {
+		missing = append(missing, "-pkg")
+	}
+	if len(missing) > 0 {
+		return s, fmt.Errorf("missing required flags %v", strings.Join(missing, ", "))
+	}
+	return s, nil
+}
+
+// splitList answers the items in a comma-separated list,
+// or nil if there are none.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var ans []string
+	for _, item := range strings.Split(s, ",") {
+		ans = append(ans, strings.TrimSpace(item))
+	}
+	return ans
+}
+
+// fail reports the error and exits with a non-zero code.
+// It does nothing if err is nil.
+func fail(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "docdrivergen:", err)
+	os.Exit(1)
+}
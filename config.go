@@ -0,0 +1,232 @@
+package drivers
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hackborn/doc_drivers/enc"
+	"github.com/hackborn/onefunc/pipeline"
+)
+
+// Config is the declarative form of MakeDriverSettings, read from
+// a JSON file. Relative paths are relative to the config file.
+// Example:
+//
+//	{
+//		"load": ["domain/*"],
+//		"targets": [
+//			{"format": "sqlite", "save": "driver/sqlite", "pkg": "sqlitedriver", "prefix": "gen"},
+//			{"format": "bbolt", "save": "driver/bbolt", "pkg": "bboltdriver", "prefix": "gen", "flags": ["lowercase"]}
+//		],
+//		"structs": {
+//			"Company": {"table": "companies", "fields": {"Id": "name(id), key"}},
+//			"Scratch": {"exclude": true}
+//		}
+//	}
+type Config struct {
+	// Load is a list of globs to the domain classes.
+	Load []string `json:"load"`
+
+	// Include and Exclude filter the structs by name or regular expression.
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+
+	Targets []TargetSettings `json:"targets"`
+
+	// Naming converts untagged names for every target.
+	Naming string `json:"naming"`
+
+	// InputHash adds a hash of the inputs to every generated file.
+	InputHash bool `json:"inputHash"`
+
+	// Structs are overrides for the domain structs, by struct name.
+	Structs map[string]StructOverride `json:"structs"`
+}
+
+// StructOverride replaces the tags of a domain struct. It allows
+// configuring structs that can't be tagged, such as third-party types.
+type StructOverride struct {
+	// Table sets the table name, as if the struct had
+	// an unexported field tagged name(Table).
+	Table string `json:"table"`
+
+	// Exclude omits the struct from the driver, as
+	// if it had a `doc:"-"` table tag.
+	Exclude bool `json:"exclude"`
+
+	// Fields replaces the doc tag of each named field.
+	Fields map[string]string `json:"fields"`
+}
+
+// ReadConfig reads a config file.
+func ReadConfig(path string) (Config, error) {
+	c := Config{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return c, fmt.Errorf("config \"%v\": %w", path, err)
+	}
+	c.resolvePaths(filepath.Dir(path))
+	return c, nil
+}
+
+// resolvePaths makes all relative paths relative to dir.
+func (c *Config) resolvePaths(dir string) {
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	for i, glob := range c.Load {
+		c.Load[i] = resolve(glob)
+	}
+	for i, t := range c.Targets {
+		t.SavePath = resolve(t.SavePath)
+		c.Targets[i] = t
+	}
+}
+
+// apply answers the settings with the config added. Settings
+// that are already set take priority.
+func (c Config) apply(s MakeDriverSettings) (MakeDriverSettings, error) {
+	if s.LoadGlob == "" && len(c.Load) > 0 {
+		s.LoadGlob = strings.Join(c.Load, configLoadSeparator)
+		s.LoadSeparator = configLoadSeparator
+	}
+	if len(s.Targets) < 1 && s.Format == "" {
+		targets, err := c.overrideTargets(s)
+		if err != nil {
+			return s, err
+		}
+		s.Targets = targets
+	}
+	if s.Include == nil {
+		s.Include = c.Include
+	}
+	if s.Naming == "" {
+		s.Naming = c.Naming
+	}
+	if s.Exclude == nil {
+		s.Exclude = c.Exclude
+	}
+	s.InputHash = s.InputHash || c.InputHash
+	if s.Structs == nil {
+		s.Structs = c.Structs
+	}
+	return s, nil
+}
+
+// overrideTargets answers the config targets with the output
+// settings that are set replacing the values of every target.
+// Targets can't share a save path, so a save path can only
+// replace the path of a single target.
+func (c Config) overrideTargets(s MakeDriverSettings) ([]TargetSettings, error) {
+	if s.SavePath != "" && len(c.Targets) > 1 {
+		return nil, fmt.Errorf("save path \"%v\" can't replace the paths of %v config targets", s.SavePath, len(c.Targets))
+	}
+	targets := slices.Clone(c.Targets)
+	for i, t := range targets {
+		t.SavePath = cmp.Or(s.SavePath, t.SavePath)
+		t.Pkg = cmp.Or(s.Pkg, t.Pkg)
+		t.Prefix = cmp.Or(s.Prefix, t.Prefix)
+		if s.Flags != nil {
+			t.Flags = s.Flags
+		}
+		targets[i] = t
+	}
+	return targets, nil
+}
+
+// applyOverrides answers the struct pins with the overrides applied.
+func applyOverrides(pins []pipeline.Pin, overrides map[string]StructOverride) ([]pipeline.Pin, error) {
+	if len(overrides) < 1 {
+		return pins, nil
+	}
+	found := make(map[string]bool)
+	var errs []error
+	var ans []pipeline.Pin
+	for _, pin := range pins {
+		sd, ok := pin.Payload.(*pipeline.StructData)
+		if !ok {
+			ans = append(ans, pin)
+			continue
+		}
+		o, ok := overrides[sd.Name]
+		if !ok {
+			ans = append(ans, pin)
+			continue
+		}
+		found[sd.Name] = true
+		sd, err := o.apply(sd)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pin.Payload = sd
+		ans = append(ans, pin)
+	}
+	for _, name := range sortedKeys(overrides) {
+		if !found[name] {
+			errs = append(errs, fmt.Errorf("override for unknown struct \"%v\"", name))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return ans, nil
+}
+
+func (o StructOverride) apply(src *pipeline.StructData) (*pipeline.StructData, error) {
+	sd := *src
+	sd.Fields = slices.Clone(src.Fields)
+	sd.UnexportedFields = slices.Clone(src.UnexportedFields)
+	// Sorted so several errors are always reported in the same order.
+	var errs []error
+	for _, name := range sortedKeys(o.Fields) {
+		idx := slices.IndexFunc(sd.Fields, func(f pipeline.StructField) bool {
+			return f.Name == name
+		})
+		if idx < 0 {
+			errs = append(errs, enc.NewFieldError(sd.Name, name, fmt.Errorf("override for unknown field")))
+			continue
+		}
+		sd.Fields[idx].Tag = o.Fields[name]
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if o.Table != "" {
+		// Table tags are read in order, so this replaces any existing name.
+		sd.UnexportedFields = append(sd.UnexportedFields, pipeline.StructField{Name: "_table", Tag: "name(" + o.Table + ")"})
+	}
+	if o.Exclude {
+		sd.UnexportedFields = append(sd.UnexportedFields, pipeline.StructField{Name: "_table", Tag: "-"})
+	}
+	return &sd, nil
+}
+
+// sortedKeys answers the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+const (
+	// Globs from a config are joined with a character
+	// that won't appear in a path.
+	configLoadSeparator = "\n"
+)
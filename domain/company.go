@@ -1,15 +1,27 @@
 package domain
 
+import (
+	"time"
+)
+
 // Company stores standard company data.
 type Company struct {
 	// A unique ID for the company.
 	Id string `doc:"name(id), key"`
 	// Friendly name. Designed as a secondary index.
 	Name string `doc:"key(b)"`
+	// Stock ticker. No two companies can share one.
+	Ticker string `json:"ticker" doc:"name(ticker), unique"`
 	// Value of the company (in some unknown units).
 	Value int64 `json:"val" doc:"name(val)"`
+	// Other names the company is known by.
+	Aliases []string `json:"aliases" doc:"name(aliases), format(gob)"`
 	// Year the company was founded.
 	FoundedYear int `json:"fy" doc:"name(fy), key(c,1)"`
+	// When the company was first stored, set by the driver.
+	Created time.Time `json:"created" doc:"name(created), created"`
+	// When the company was last stored, in milliseconds.
+	Updated int64 `json:"updated" doc:"name(updated), updated"`
 	// Do not include this in the driver.
 	Skip int `doc:"-"`
 	// Private fields are treated as table specs
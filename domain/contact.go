@@ -0,0 +1,28 @@
+package domain
+
+// Contact tests flattened structs. The embedded Record is
+// flattened automatically, and Home is stored in columns
+// with a prefix. Phone is encrypted.
+type Contact struct {
+	Record
+	Name  string  `json:"name" doc:"name(name)"`
+	Home  Address `json:"home" doc:"flatten(home_)"`
+	Phone string  `json:"phone" doc:"name(phone), encrypt"`
+	// Private fields are treated as table specs
+	_table int `doc:"name(contact)"`
+}
+
+// Record holds the fields shared by the stored types.
+type Record struct {
+	Id string `json:"id" doc:"name(id), key"`
+
+	_table int `doc:"-"`
+}
+
+// Address is only stored in the structs that flatten it.
+type Address struct {
+	Street string `json:"street" doc:"name(street)"`
+	City   string `json:"city" doc:"name(city), index"`
+
+	_table int `doc:"-"`
+}
@@ -0,0 +1,13 @@
+package domain
+
+// Document is edited by concurrent writers. A write
+// fails unless it has the latest version.
+type Document struct {
+	Id string `doc:"name(id), key"`
+	// Stored as "text" before it was renamed.
+	Body string `json:"body" doc:"name(body), was(text)"`
+	// Incremented by the driver on every write.
+	Version int64 `json:"version" doc:"name(version), version"`
+	// Private fields are treated as table specs
+	_table int `doc:"name(document)"`
+}
@@ -10,9 +10,9 @@ type Filing struct {
 	// Amount of filing.
 	Value int64 `json:"val" doc:"name(val)"`
 	// Units used for the value (i.e. "usd").
-	Units string `json:"units"`
+	Units string `json:"units" doc:"default(usd), notnull"`
 	// Fiscal year of the filing
-	FiscalYear int `json:"fy" doc:"name(fy)"`
+	FiscalYear int `json:"fy" doc:"name(fy), index"`
 	// Private fields are treated as table specs
 	_table int `doc:"name(filing)"`
 }
@@ -0,0 +1,65 @@
+package driverkit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnique matches every unique constraint violation,
+// from any generated driver.
+// Example:
+//
+//	if errors.Is(err, driverkit.ErrUnique) {
+//		// The value is taken
+//	}
+var ErrUnique = errors.New("unique constraint violation")
+
+// UniqueError is returned from Set when the item would
+// violate a unique constraint or index. Nothing is written.
+type UniqueError struct {
+	// Table is the name of the table, or the root
+	// bucket for bbolt.
+	Table string
+
+	// Columns are the column names in the constraint.
+	Columns []string
+
+	// Err is the error reported by the database, if any.
+	Err error
+}
+
+func (e *UniqueError) Error() string {
+	return fmt.Sprintf("unique constraint violation on %v (%v)", e.Table, strings.Join(e.Columns, ", "))
+}
+
+func (e *UniqueError) Is(target error) bool {
+	return target == ErrUnique
+}
+
+func (e *UniqueError) Unwrap() error {
+	return e.Err
+}
+
+// ErrConflict matches every version conflict,
+// from any generated driver.
+var ErrConflict = errors.New("version conflict")
+
+// ConflictError is returned from Set when the item's
+// version isn't the stored version. Nothing is written.
+type ConflictError struct {
+	// Table is the name of the table, or the root
+	// bucket for bbolt.
+	Table string
+
+	// Version is the version of the item.
+	Version int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("version conflict on %v: version %v is not the stored version", e.Table, e.Version)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
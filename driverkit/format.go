@@ -0,0 +1,137 @@
+package driverkit
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// FieldFormat converts field values to and from the bytes
+// stored in the database, for fields tagged format(name).
+type FieldFormat interface {
+	Marshal(v any) ([]byte, error)
+
+	// Unmarshal decodes data into v, a pointer to the field.
+	Unmarshal(data []byte, v any) error
+}
+
+// RegisterFormat adds a format that can be selected with the
+// format(name) tag, in every generated driver. Formats should
+// be registered before a driver is opened, i.e. from an init().
+// Registering a built-in format (json, gob, text, binary) panics.
+func RegisterFormat(name string, f FieldFormat) {
+	formatsMut.Lock()
+	defer formatsMut.Unlock()
+	if _, ok := builtinFormats[name]; ok {
+		panic(fmt.Sprintf("format \"%v\" is built in", name))
+	}
+	formats[name] = f
+}
+
+// FindFormat answers the built-in or registered format
+// with the name.
+func FindFormat(name string) (FieldFormat, error) {
+	if f, ok := builtinFormats[name]; ok {
+		return f, nil
+	}
+	formatsMut.RLock()
+	defer formatsMut.RUnlock()
+	if f, ok := formats[name]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("Format \"%v\" is not registered", name)
+}
+
+type jsonFormat struct{}
+
+func (f jsonFormat) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (f jsonFormat) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobFormat struct{}
+
+func (f gobFormat) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (f gobFormat) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// textFormat uses the encoding.TextMarshaler
+// and encoding.TextUnmarshaler of the field.
+type textFormat struct{}
+
+func (f textFormat) Marshal(v any) ([]byte, error) {
+	m, ok := asInterface[encoding.TextMarshaler](v)
+	if !ok {
+		return nil, fmt.Errorf("Format \"text\" requires an encoding.TextMarshaler, has %T", v)
+	}
+	return m.MarshalText()
+}
+
+func (f textFormat) Unmarshal(data []byte, v any) error {
+	u, ok := v.(encoding.TextUnmarshaler)
+	if !ok {
+		return fmt.Errorf("Format \"text\" requires an encoding.TextUnmarshaler, has %T", v)
+	}
+	return u.UnmarshalText(data)
+}
+
+// binaryFormat uses the encoding.BinaryMarshaler
+// and encoding.BinaryUnmarshaler of the field.
+type binaryFormat struct{}
+
+func (f binaryFormat) Marshal(v any) ([]byte, error) {
+	m, ok := asInterface[encoding.BinaryMarshaler](v)
+	if !ok {
+		return nil, fmt.Errorf("Format \"binary\" requires an encoding.BinaryMarshaler, has %T", v)
+	}
+	return m.MarshalBinary()
+}
+
+func (f binaryFormat) Unmarshal(data []byte, v any) error {
+	u, ok := v.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("Format \"binary\" requires an encoding.BinaryUnmarshaler, has %T", v)
+	}
+	return u.UnmarshalBinary(data)
+}
+
+// asInterface answers v as T, including when only
+// a pointer to v implements T.
+func asInterface[T any](v any) (T, bool) {
+	if t, ok := v.(T); ok {
+		return t, true
+	}
+	if v == nil {
+		var t T
+		return t, false
+	}
+	ptr := reflect.New(reflect.TypeOf(v))
+	ptr.Elem().Set(reflect.ValueOf(v))
+	t, ok := ptr.Interface().(T)
+	return t, ok
+}
+
+var (
+	builtinFormats = map[string]FieldFormat{
+		"json":   jsonFormat{},
+		"gob":    gobFormat{},
+		"text":   textFormat{},
+		"binary": binaryFormat{},
+	}
+
+	formats    = make(map[string]FieldFormat)
+	formatsMut sync.RWMutex
+)
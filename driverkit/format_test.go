@@ -0,0 +1,49 @@
+package driverkit
+
+import (
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------
+// TEST-FORMAT
+func TestFormat(t *testing.T) {
+	RegisterFormat("testupper", upperFormat{})
+	f := func(name string, v any, want string) {
+		t.Helper()
+
+		format, err := FindFormat(name)
+		if err != nil {
+			t.Fatalf("Has err %v", err)
+		}
+		have, err := format.Marshal(v)
+		if err != nil {
+			t.Fatalf("Has err %v", err)
+		} else if string(have) != want {
+			t.Fatalf("Has %v but wants %v", string(have), want)
+		}
+	}
+	f("json", []int{1, 2}, `[1,2]`)
+	f("testupper", "abc", `ABC`)
+	if _, err := FindFormat("missing"); err == nil {
+		t.Fatalf("Has no err for an unregistered format")
+	}
+	// Built-in formats can't be replaced.
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Has no panic registering a built in format")
+		}
+	}()
+	RegisterFormat("json", upperFormat{})
+}
+
+type upperFormat struct{}
+
+func (f upperFormat) Marshal(v any) ([]byte, error) {
+	return []byte(strings.ToUpper(v.(string))), nil
+}
+
+func (f upperFormat) Unmarshal(data []byte, v any) error {
+	*(v.(*string)) = strings.ToLower(string(data))
+	return nil
+}
@@ -0,0 +1,25 @@
+package driverkit
+
+import (
+	"errors"
+)
+
+// KeyProvider answers the keys that seal the fields tagged
+// encrypt. It's passed to a generated driver's NewDriverWithKeys.
+type KeyProvider interface {
+	// Key answers the AES key for the table, which
+	// must be 16, 24 or 32 bytes.
+	Key(table string) ([]byte, error)
+}
+
+// StaticKey is a KeyProvider that answers the same key
+// for every table.
+type StaticKey []byte
+
+func (k StaticKey) Key(table string) ([]byte, error) {
+	return k, nil
+}
+
+// ErrNoKeyProvider is returned when an encrypted field
+// is read or written by a driver without a KeyProvider.
+var ErrNoKeyProvider = errors.New("encrypted fields require a KeyProvider")
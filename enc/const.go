@@ -13,4 +13,25 @@ const (
 	FlagEnd = iota
 	// Clients that want to add flags should use:
 	// 	AnotherFlag enc.Flags = 1 << (iota + enc.FlagEnd)
+	// and set them from a keyword added with RegisterKeyword.
 )
+
+// Compressions are the algorithms of the compress keyword.
+const (
+	CompressGzip  = "gzip"
+	CompressFlate = "flate"
+)
+
+var compressions = []string{CompressGzip, CompressFlate}
+
+// Names answers the flags in their tag form.
+func (f Flags) Names() []string {
+	var names []string
+	if f&FlagAutoIncGlobal != 0 {
+		names = append(names, "autoinc")
+	}
+	if f&FlagAutoIncLocal != 0 {
+		names = append(names, "autoinc(local)")
+	}
+	return names
+}
@@ -1,8 +1,11 @@
 package enc
 
 import (
+	"cmp"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/hackborn/onefunc/jacl"
@@ -37,9 +40,205 @@ func TestParseTag(t *testing.T) {
 		}
 	}
 	f("name(id)", nil, `Name=id`, `HasKey=false`)
-	f("name(id), key", nil, `Name=id`, `HasKey=t`)
-	f("name(id), key(a,1)", nil, `Name=id`, `HasKey=t`, `KeyGroup=a`, `KeyIndex=1`)
-	f(`key, autoinc`, nil, `Name=""`, `HasKey=t`, `KeyGroup=""`, `KeyIndex=0`, `Flags=1`)
+	f("name(id), key", nil, `Name=id`, `HasKey=true`)
+	f("name(id), key(a,1)", nil, `Name=id`, `HasKey=true`, `KeyGroup=a`, `KeyIndex=1`)
+	f(`key, autoinc`, nil, `Name=""`, `HasKey=true`, `KeyGroup=""`, `KeyIndex=0`, `Flags=1`)
 	f(`key, autoinc(local)`, nil, `Flags=2`)
 	f(`format(json)`, nil, `Format=json`)
+	f(`index(a)`, nil, `Indexes/0/Name=a`, `Indexes/0/Index=0`, `Indexes/0/Unique=false`)
+	f(`index(a, 1, unique)`, nil, `Indexes/0/Name=a`, `Indexes/0/Index=1`, `Indexes/0/Unique=true`)
+	f(`index(, unique)`, nil, `Indexes/0/Name=""`, `Indexes/0/Unique=true`)
+	f(`index, name(b)`, nil, `Name=b`, `Indexes/{count}=1`, `Indexes/0/Name=""`)
+	f(`index(a), index(b, 0, unique)`, nil, `Indexes/{count}=2`, `Indexes/1/Name=b`, `Indexes/1/Unique=true`)
+	f(`index(unique)`, fmt.Errorf("Index unique must follow the name"))
+	f(`unique`, nil, `Uniques/{count}=1`, `Uniques/0=""`)
+	f(`unique(a), unique(b)`, nil, `Uniques/{count}=2`, `Uniques/0=a`, `Uniques/1=b`)
+	f(`unique(a b)`, fmt.Errorf("Unique takes a single name on token \"b\""))
+	f(`type(TEXT)`, nil, `Type=TEXT`, `Size=0`)
+	f(`type(DECIMAL(10, 2)), name(a)`, nil, `Type="DECIMAL(10, 2)"`, `Name=a`)
+	f(`size(64)`, nil, `Size=64`)
+	f(`type(CHAR), size(8)`, nil, `Type=CHAR`, `Size=8`)
+	f(`type`, fmt.Errorf("Type requires a value"))
+	f(`size(0)`, fmt.Errorf("Size requires a positive value"))
+	f(`size(a)`, fmt.Errorf("illegal size \"a\""))
+	f(`default(usd)`, nil, `HasDefault=true`, `Default=usd`)
+	f(`default(-1), notnull`, nil, `Default="-1"`, `NotNull=true`, `Nullable=false`)
+	f(`default("a, (b)"), nullable`, nil, `Default="a, (b)"`, `Nullable=true`)
+	f(`default(), name(a)`, nil, `HasDefault=true`, `Default=""`, `Name=a`)
+	f(`default`, fmt.Errorf("Default requires a value"))
+	f(`notnull(a)`, fmt.Errorf("Unexpected argument \"a\""))
+	f(`created`, nil, `Created=true`, `Updated=false`)
+	f(`name(u), updated`, nil, `Name=u`, `Updated=true`)
+	f(`version`, nil, `Version=true`)
+	f(`inline`, nil, `Inline=true`, `Flatten=""`)
+	f(`flatten(home_), name(city)`, nil, `Flatten=home_`, `Name=city`, `Inline=false`)
+	f(`flatten`, fmt.Errorf("Flatten requires a prefix"))
+	f(`name(companies), naming(snake_case)`, nil, `Name=companies`, `Naming=snake_case`)
+	f(`naming`, fmt.Errorf("Naming requires a strategy"))
+	f(`name(fy), was(year, founded)`, nil, `Name=fy`, `Was/0=year`, `Was/1=founded`)
+	f(`was`, fmt.Errorf("Was requires a name"))
+	f(`name(ssn), encrypt`, nil, `Name=ssn`, `Encrypt=true`)
+	f(`encrypt(a)`, fmt.Errorf("Unexpected argument \"a\""))
+	f(`format(gob), compress(gzip)`, nil, `Format=gob`, `Compress=gzip`)
+	f(`compress(flate), encrypt`, nil, `Compress=flate`, `Encrypt=true`)
+	f(`compress`, fmt.Errorf("Compress requires an algorithm"))
+	f(`compress(zstd)`, fmt.Errorf("Unknown compression \"zstd\", must be one of gzip, flate"))
+	f(`naming(kebab)`, fmt.Errorf("Unknown naming \"kebab\", must be one of snake_case, lower, camel, verbatim"))
+	f(`name(id), key, autoinc`, nil, `Keywords/0=name`, `Keywords/1=key`, `Keywords/2=autoinc`)
+}
+
+// ---------------------------------------------------------
+// TEST-APPLY-NAMING
+func TestApplyNaming(t *testing.T) {
+	f := func(naming, name, want string) {
+		t.Helper()
+
+		if have := ApplyNaming(naming, name); have != want {
+			t.Fatalf("%v of %v wants %v but has %v", naming, name, want, have)
+		}
+	}
+	f(NamingSnakeCase, "FoundedYear", "founded_year")
+	f(NamingSnakeCase, "UserID", "user_id")
+	f(NamingSnakeCase, "HTTPServer", "http_server")
+	f(NamingSnakeCase, "Base64Data", "base64_data")
+	f(NamingSnakeCase, "Already_Snake", "already_snake")
+	f(NamingSnakeCase, "Id", "id")
+	f(NamingLower, "FoundedYear", "foundedyear")
+	f(NamingCamel, "FoundedYear", "foundedYear")
+	f(NamingCamel, "ID", "id")
+	f(NamingCamel, "HTTPServer", "httpServer")
+	f(NamingVerbatim, "FoundedYear", "FoundedYear")
+	f("", "FoundedYear", "FoundedYear")
+}
+
+// ---------------------------------------------------------
+// TEST-DEFAULT-VALUE
+func TestDefaultValue(t *testing.T) {
+	f := func(expr, goType string, want any, wantErr error) {
+		t.Helper()
+
+		tag, err := ParseTag(expr)
+		if err != nil {
+			t.Fatalf("Has parse err %v", err)
+		}
+		have, haveErr := tag.DefaultValue(goType)
+		if err := jacl.RunErr(haveErr, wantErr); err != nil {
+			t.Fatalf("Want err %v but have %v (%v)", wantErr, haveErr, err)
+		} else if have != want {
+			t.Fatalf("Want %v (%T) but has %v (%T)", want, want, have, have)
+		}
+	}
+	f(`default(usd)`, "string", "usd", nil)
+	f(`default(-1)`, "int", int64(-1), nil)
+	f(`default(2)`, "uint32", uint64(2), nil)
+	f(`default(1.5)`, "float64", 1.5, nil)
+	f(`default(true)`, "bool", true, nil)
+	f(`default(x)`, "int", nil, fmt.Errorf("illegal default \"x\" for int"))
+	f(`default(x)`, "unknown", nil, fmt.Errorf("Default requires a bool, number or string field"))
+}
+
+// ---------------------------------------------------------
+// TEST-CHECK-TYPE
+func TestCheckType(t *testing.T) {
+	f := func(expr, rawType string, wantErr error) {
+		t.Helper()
+
+		tag, err := ParseTag(expr)
+		if err != nil {
+			t.Fatalf("Has parse err %v", err)
+		}
+		haveErr := cmp.Or(tag.Validate(), tag.CheckType(rawType))
+		if err := jacl.RunErr(haveErr, wantErr); err != nil {
+			t.Fatalf("Want err %v but have %v (%v)", wantErr, haveErr, err)
+		}
+	}
+	f(`created`, "time.Time", nil)
+	f(`updated`, "int64", nil)
+	f(`name(a)`, "string", nil)
+	f(`updated`, "string", fmt.Errorf("Tag updated requires a time.Time or int64 field"))
+	f(`created, updated`, "int64", fmt.Errorf("Tag created and updated can't both be set"))
+	f(`key, created`, "int64", fmt.Errorf("Tag created can't be set on keys"))
+	f(`version`, "uint32", nil)
+	f(`version`, "float64", fmt.Errorf("Tag version requires an integer field"))
+	f(`key, version`, "int64", fmt.Errorf("Tag version can't be set on keys or timestamps"))
+	f(`encrypt, format(json)`, "[]string", nil)
+	f(`key, encrypt`, "string", fmt.Errorf("Tag encrypt can't be set on keys"))
+	f(`index, encrypt`, "string", fmt.Errorf("Tag encrypt can't be set on indexes"))
+	f(`unique(email), encrypt`, "string", fmt.Errorf("Tag encrypt can't be set on indexes"))
+	f(`updated, encrypt`, "int64", fmt.Errorf("Tag encrypt can't be set on timestamps, versions or defaults"))
+	f(`key, compress(gzip)`, "string", fmt.Errorf("Tag compress can't be set on keys"))
+	f(`index, compress(flate)`, "string", fmt.Errorf("Tag compress can't be set on indexes"))
+}
+
+// ---------------------------------------------------------
+// TEST-SUPPORT
+func TestSupport(t *testing.T) {
+	s := Support{Backend: "test", Keywords: []string{"name", "key", "autoinc"}, Flags: FlagAutoIncGlobal}
+	f := func(expr string, wantErr error) {
+		t.Helper()
+
+		tag, err := ParseTag(expr)
+		if err != nil {
+			t.Fatalf("Has parse err %v", err)
+		}
+		haveErr := s.Check(tag)
+		if err := jacl.RunErr(haveErr, wantErr); err != nil {
+			t.Fatalf("Want err %v but have %v (%v)", wantErr, haveErr, err)
+		}
+	}
+	f(`name(id), key, autoinc`, nil)
+	f(`format(json)`, fmt.Errorf(`Tag keyword "format" is not supported by test`))
+	f(`key, autoinc(local)`, fmt.Errorf(`Tag keyword "autoinc(local)" is not supported by test`))
+}
+
+// ---------------------------------------------------------
+// TEST-REGISTER-KEYWORD
+func TestRegisterKeyword(t *testing.T) {
+	const flagTest Flags = 1 << (iota + FlagEnd)
+	RegisterKeyword("testttl", func(tag *Tag, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("want 1 arg")
+		}
+		tag.SetExtra("ttl", args[0])
+		return nil
+	})
+	RegisterKeyword("testflag", func(tag *Tag, args []string) error {
+		tag.Flags |= flagTest
+		return nil
+	})
+	RegisterKeyword("testargs", func(tag *Tag, args []string) error {
+		tag.SetExtra("args", strings.Join(args, "|"))
+		return nil
+	})
+	f := func(expr string, wantErr error, want ...string) {
+		t.Helper()
+
+		have, haveErr := ParseTag(expr)
+		if err := jacl.RunErr(haveErr, wantErr); err != nil {
+			t.Fatalf("Want err %v but have %v (%v)", wantErr, haveErr, err)
+		} else if err := jacl.Run(have, want...); err != nil {
+			t.Fatalf("Want %v but has %v (%v)", want, have, err)
+		}
+	}
+	f(`key, testttl(24h)`, nil, `HasKey=true`, `Extra/ttl="24h"`, `Keywords/1=testttl`)
+	f(`testttl(1h30m), name(a)`, nil, `Name=a`, `Extra/ttl="1h30m"`)
+	f(`testflag, key`, nil, `Flags=4`, `HasKey=true`)
+	f(`testargs(a, b c,,d)`, nil, `Extra/args="a|b c||d"`)
+	f(`testttl`, fmt.Errorf(`Keyword "testttl": want 1 arg`))
+	// Registered keywords are not checked by backends.
+	tag, _ := ParseTag(`testflag, testttl(1h)`)
+	if err := (Support{Backend: "test"}).Check(tag); err != nil {
+		t.Fatalf("Has support err %v", err)
+	}
+	// Every keyword the parser handles is reserved.
+	for name := range builtinKeywords {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Has no panic registering built in keyword %v", name)
+				}
+			}()
+			RegisterKeyword(name, func(*Tag, []string) error { return nil })
+		}()
+	}
 }
@@ -0,0 +1,68 @@
+package enc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FieldError reports an error on a single field of a domain
+// struct, or on the struct itself if Field is empty. File and
+// Line are filled in once the source position is known.
+type FieldError struct {
+	Struct string
+	Field  string
+	File   string
+	Line   int
+	Err    error
+}
+
+// NewFieldError answers a FieldError wrapping err, or nil
+// if err is nil.
+func NewFieldError(structName, fieldName string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &FieldError{Struct: structName, Field: fieldName, Err: err}
+}
+
+// NewStructError answers a FieldError for the struct
+// wrapping err, or nil if err is nil.
+func NewStructError(structName string, err error) error {
+	return NewFieldError(structName, "", err)
+}
+
+func (e *FieldError) Error() string {
+	s := e.Struct
+	if e.Field != "" {
+		s += "." + e.Field
+	}
+	if e.File != "" {
+		s = fmt.Sprintf("%v:%v: %v", e.File, e.Line, s)
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ListBlock is an errors.Block that collects every error,
+// so a single run can report all the problems in a domain.
+type ListBlock struct {
+	Errs []error
+}
+
+func (b *ListBlock) AddError(err error) {
+	if err != nil {
+		b.Errs = append(b.Errs, err)
+	}
+}
+
+func (b *ListBlock) HasError() bool {
+	return len(b.Errs) > 0
+}
+
+// Err answers all the errors joined, or nil if there are none.
+func (b *ListBlock) Err() error {
+	return errors.Join(b.Errs...)
+}
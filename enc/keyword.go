@@ -0,0 +1,94 @@
+package enc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// KeywordFunc handles a registered tag keyword. Args are the
+// comma-separated arguments inside the parens, with surrounding
+// space removed, or empty if the keyword has no parens. The
+// handler writes its result into the tag, either as a value
+// in Extra or as a custom flag.
+type KeywordFunc func(tag *Tag, args []string) error
+
+// RegisterKeyword adds a keyword to the tag grammar. Keywords
+// are global, and should be registered before any tags are
+// parsed, i.e. from an init(). Registering a built-in keyword
+// panics.
+// Example:
+//
+//	enc.RegisterKeyword("ttl", func(tag *enc.Tag, args []string) error {
+//		d, err := time.ParseDuration(strings.Join(args, ""))
+//		tag.SetExtra("ttl", d)
+//		return err
+//	})
+func RegisterKeyword(name string, fn KeywordFunc) {
+	if _, ok := builtinKeywords[name]; ok {
+		panic(fmt.Sprintf("enc: keyword \"%v\" is built in", name))
+	}
+	keywordsMut.Lock()
+	defer keywordsMut.Unlock()
+	keywords[name] = fn
+}
+
+func findKeyword(name string) (KeywordFunc, bool) {
+	keywordsMut.RLock()
+	defer keywordsMut.RUnlock()
+	fn, ok := keywords[name]
+	return fn, ok
+}
+
+// tagParserRegisteredHandler collects the arguments
+// for a registered keyword.
+type tagParserRegisteredHandler struct {
+	name  string
+	fn    KeywordFunc
+	args  []string
+	start int
+	end   int
+}
+
+func (h *tagParserRegisteredHandler) Start(*tagParserState) {
+	h.start = -1
+}
+
+func (h *tagParserRegisteredHandler) End(s *tagParserState) {
+	if err := h.fn(&s.tag, h.args); err != nil {
+		s.eb.AddError(fmt.Errorf("Keyword \"%v\": %w", h.name, err))
+	}
+}
+
+func (h *tagParserRegisteredHandler) Handle(args tagParserArgs) {
+	switch args.text {
+	case ")":
+		if h.start >= 0 || len(h.args) > 0 {
+			h.addArg(args.state)
+		}
+		args.state.pop()
+	case ",":
+		h.addArg(args.state)
+	default:
+		// Args are sliced from the expression, so a value
+		// scanned as several tokens, like 24h, stays whole.
+		if h.start < 0 {
+			h.start = args.offset
+		}
+		h.end = args.offset + len(args.text)
+	}
+}
+
+func (h *tagParserRegisteredHandler) addArg(s *tagParserState) {
+	arg := ""
+	if h.start >= 0 {
+		arg = strings.TrimSpace(s.expr[h.start:h.end])
+	}
+	h.args = append(h.args, arg)
+	h.start = -1
+}
+
+var (
+	keywords    = make(map[string]KeywordFunc)
+	keywordsMut sync.RWMutex
+)
@@ -0,0 +1,85 @@
+package enc
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Naming strategies convert the Go names of structs and
+// fields to database names, for any without a name tag.
+const (
+	// NamingSnakeCase lowercases and separates words with
+	// underscores, i.e. FoundedYear is founded_year.
+	NamingSnakeCase = "snake_case"
+	// NamingLower lowercases, i.e. FoundedYear is foundedyear.
+	NamingLower = "lower"
+	// NamingCamel lowercases the first word, i.e.
+	// FoundedYear is foundedYear.
+	NamingCamel = "camel"
+	// NamingVerbatim leaves the name unchanged.
+	NamingVerbatim = "verbatim"
+)
+
+var namings = []string{NamingSnakeCase, NamingLower, NamingCamel, NamingVerbatim}
+
+// CheckNaming answers an error if the naming isn't a
+// known strategy. An empty naming is the backend default.
+func CheckNaming(naming string) error {
+	if naming != "" && !slices.Contains(namings, naming) {
+		return fmt.Errorf("Unknown naming \"%v\", must be one of %v", naming, strings.Join(namings, ", "))
+	}
+	return nil
+}
+
+// ApplyNaming answers the name converted with the naming.
+// An unknown or empty naming leaves the name unchanged.
+func ApplyNaming(naming, name string) string {
+	switch naming {
+	case NamingSnakeCase:
+		return snakeCase(name)
+	case NamingLower:
+		return strings.ToLower(name)
+	case NamingCamel:
+		return camelCase(name)
+	}
+	return name
+}
+
+// snakeCase separates the words of the name. A word starts
+// at an upper case letter that follows a lower case letter
+// or digit, or that ends a run of upper case letters, so
+// UserID is user_id and HTTPServer is http_server.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && runes[i-1] != '_' {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				sb.WriteRune('_')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+// camelCase lowercases the leading run of upper case letters,
+// except for the start of the following word, so ID is id
+// and HTTPServer is httpServer.
+func camelCase(name string) string {
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsUpper(r) {
+			break
+		}
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(r)
+	}
+	return string(runes)
+}
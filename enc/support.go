@@ -0,0 +1,37 @@
+package enc
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Support describes the tag keywords and flags a backend
+// can honor. Tags are parsed the same for every backend,
+// so each backend checks the results against its support.
+type Support struct {
+	// Backend is the name of the backend, used in errors.
+	Backend string
+
+	// Keywords are the supported tag keywords.
+	Keywords []string
+
+	// Flags are the supported flags.
+	Flags Flags
+}
+
+// Check answers an error if the tag uses a built-in keyword
+// or flag that isn't supported. Registered keywords are owned
+// by whoever registered them, so they aren't checked.
+func (s Support) Check(t Tag) error {
+	for _, kw := range t.Keywords {
+		if _, ok := builtinKeywords[kw]; ok && !slices.Contains(s.Keywords, kw) {
+			return fmt.Errorf("Tag keyword \"%v\" is not supported by %v", kw, s.Backend)
+		}
+	}
+	// Custom flags are owned by their keywords, the same as above.
+	builtin := Flags(1<<FlagEnd - 1)
+	if unsupported := t.Flags & builtin &^ s.Flags; unsupported != 0 {
+		return fmt.Errorf("Tag keyword \"%v\" is not supported by %v", unsupported.Names()[0], s.Backend)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package enc
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"text/scanner"
@@ -16,19 +17,183 @@ type Tag struct {
 	KeyGroup string
 	KeyIndex int
 	Flags    Flags
+	// Indexes are the secondary indexes the field belongs to.
+	Indexes []IndexTag
+	// Uniques are the unique constraints the field belongs
+	// to. Fields with the same name form a compound constraint.
+	// An empty name is a constraint on the field alone.
+	Uniques []string
+	// Default is the value for fields that have none
+	// stored, such as records from before the field
+	// existed. Only set if HasDefault is true.
+	Default    string
+	HasDefault bool
+	// Type replaces the column type chosen by the backend,
+	// i.e. TEXT or BLOB. Size is the column size, i.e. the
+	// n in VARCHAR(n), or 0.
+	Type string
+	Size int
+	// NotNull and Nullable control whether the column
+	// accepts NULL. Fields are nullable unless they're keys.
+	NotNull  bool
+	Nullable bool
+	// Created and Updated are timestamps the driver sets,
+	// when the record is first stored and on every store.
+	Created bool
+	Updated bool
+	// Version is an integer the driver increments on every
+	// store. A store only succeeds if the item has the version
+	// that is stored.
+	Version bool
+	// Inline expands the fields of a struct field in place,
+	// as if they were declared in the containing struct.
+	// Flatten does the same, adding a prefix to the names.
+	// The loader passes the prefix on to each expanded field,
+	// so backends add it to the name of a single field.
+	Inline  bool
+	Flatten string
+	// Naming is the naming strategy for the table and
+	// columns without a name. It's only set on table tags.
+	Naming string
+	// Was are the names the field was stored under before
+	// it was renamed, so the backend can migrate the data.
+	Was []string
+	// Encrypt seals the value before it's stored and opens
+	// it after it's read, with a key the driver is given.
+	Encrypt bool
+	// Compress is the algorithm that compresses the value
+	// before it's stored, either gzip or flate. On a table
+	// tag it compresses the whole record, if the backend can.
+	Compress string
+	// Keywords are the keywords used in the tag, in order.
+	Keywords []string
+	// Extra holds the values written by registered keywords,
+	// by whatever key the keyword handler chooses.
+	Extra map[string]any
 	// True if the autoinc tag is set. Indicates this value
 	// should be automatically set on item creation.
 	// Deprecated, use flags
 	//	AutoInc bool
 }
 
+// IndexTag describes the field's place in a secondary index.
+type IndexTag struct {
+	// Name of the index. Fields with the same name
+	// form a compound index. If empty, the backend
+	// names the index after the field.
+	Name string
+	// Index orders the fields in a compound index.
+	Index int
+	// Unique is true if the index values must be unique.
+	Unique bool
+}
+
 func (t Tag) Validate() error {
 	if t.Autoinc() && t.HasKey == false {
 		return fmt.Errorf("Tag autoinc can only be set on keys")
 	}
+	if t.NotNull && t.Nullable {
+		return fmt.Errorf("Tag notnull and nullable can't both be set")
+	}
+	if t.HasKey && t.HasDefault {
+		return fmt.Errorf("Tag default can't be set on keys")
+	}
+	if t.HasKey && t.Nullable {
+		return fmt.Errorf("Tag nullable can't be set on keys")
+	}
+	if t.Created && t.Updated {
+		return fmt.Errorf("Tag created and updated can't both be set")
+	}
+	if t.HasKey && (t.Created || t.Updated) {
+		return fmt.Errorf("Tag %v can't be set on keys", t.stampKeyword())
+	}
+	if t.Version && (t.HasKey || t.Created || t.Updated) {
+		return fmt.Errorf("Tag version can't be set on keys or timestamps")
+	}
+	// Sealed values are only bytes to the database.
+	if t.sealed() && t.HasKey {
+		return fmt.Errorf("Tag %v can't be set on keys", t.sealKeyword())
+	}
+	if t.sealed() && (len(t.Indexes) > 0 || len(t.Uniques) > 0) {
+		return fmt.Errorf("Tag %v can't be set on indexes", t.sealKeyword())
+	}
+	if t.sealed() && (t.Created || t.Updated || t.Version || t.HasDefault) {
+		return fmt.Errorf("Tag %v can't be set on timestamps, versions or defaults", t.sealKeyword())
+	}
+	return nil
+}
+
+// CheckType answers an error if the raw Go type can't hold
+// the value the driver sets, for timestamps and versions.
+func (t Tag) CheckType(rawType string) error {
+	switch {
+	case t.Created || t.Updated:
+		if rawType != "time.Time" && rawType != "int64" {
+			return fmt.Errorf("Tag %v requires a time.Time or int64 field", t.stampKeyword())
+		}
+	case t.Version:
+		switch rawType {
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		default:
+			return fmt.Errorf("Tag version requires an integer field")
+		}
+	}
 	return nil
 }
 
+// sealed answers true if the value is encrypted or
+// compressed, so it's stored as bytes.
+func (t Tag) sealed() bool {
+	return t.Encrypt || t.Compress != ""
+}
+
+func (t Tag) sealKeyword() string {
+	if t.Encrypt {
+		return "encrypt"
+	}
+	return "compress"
+}
+
+func (t Tag) stampKeyword() string {
+	if t.Created {
+		return "created"
+	}
+	return "updated"
+}
+
+// DefaultValue answers the default converted to the Go type,
+// which must be a bool, number or string.
+func (t Tag) DefaultValue(goType string) (any, error) {
+	var v any
+	var err error
+	switch goType {
+	case "bool":
+		v, err = strconv.ParseBool(t.Default)
+	case "int", "int8", "int16", "int32", "int64":
+		v, err = strconv.ParseInt(t.Default, 10, 64)
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		v, err = strconv.ParseUint(t.Default, 10, 64)
+	case "float", "float32", "float64":
+		v, err = strconv.ParseFloat(t.Default, 64)
+	case "string":
+		v = t.Default
+	default:
+		return nil, fmt.Errorf("Default requires a bool, number or string field")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("illegal default \"%v\" for %v", t.Default, goType)
+	}
+	return v, nil
+}
+
+// SetExtra sets a value in the Extra map.
+func (t *Tag) SetExtra(key string, value any) {
+	if t.Extra == nil {
+		t.Extra = make(map[string]any)
+	}
+	t.Extra[key] = value
+}
+
 func (t Tag) Autoinc() bool {
 	return t.AutoincGlobal() || t.AutoincLocal()
 }
@@ -51,12 +216,12 @@ func ParseTag(expr string) (Tag, error) {
 	lexer.Error = func(s *scanner.Scanner, msg string) {
 		eb.AddError(fmt.Errorf("key scan error: %v", msg))
 	}
-	state := &tagParserState{eb: eb}
+	state := &tagParserState{expr: expr, eb: eb}
 	state.push(&tagParserKeywordHandler{})
 	args := tagParserArgs{state: state}
 	for tok := lexer.Scan(); tok != scanner.EOF; tok = lexer.Scan() {
 		//		fmt.Println("TOK", tok, "name", scanner.TokenString(tok), "text", lexer.TokenText())
-		args.token, args.text = tok, lexer.TokenText()
+		args.token, args.text, args.offset = tok, lexer.TokenText(), lexer.Position.Offset
 		state.handle(args)
 	}
 	for len(state.stack) > 0 {
@@ -66,9 +231,10 @@ func ParseTag(expr string) (Tag, error) {
 }
 
 type tagParserArgs struct {
-	state *tagParserState
-	token rune
-	text  string
+	state  *tagParserState
+	token  rune
+	text   string
+	offset int // Offset of the token in the expression
 }
 
 // tagParserHandler defines a token handler.
@@ -80,8 +246,9 @@ type tagParserHandler interface {
 
 // tagParserState contains the parsing stack and other state.
 type tagParserState struct {
-	tag Tag
-	eb  oferrors.Block
+	expr string
+	tag  Tag
+	eb   oferrors.Block
 
 	stack []tagParserHandler
 }
@@ -94,6 +261,12 @@ func (s *tagParserState) handle(args tagParserArgs) {
 	}
 }
 
+func (s *tagParserState) addKeyword(kw string) {
+	if !slices.Contains(s.tag.Keywords, kw) {
+		s.tag.Keywords = append(s.tag.Keywords, kw)
+	}
+}
+
 func (s *tagParserState) push(h tagParserHandler) {
 	if h == nil {
 		s.eb.AddError(fmt.Errorf("No handler for keyword"))
@@ -131,19 +304,68 @@ func (h *tagParserKeywordHandler) Handle(args tagParserArgs) {
 	case ",":
 		// This shouldn't be hit, it's always the wrapper
 		args.state.pop()
-	case "name":
-		args.state.push(&tagParserHandlerWrapper{inner: &tagParserNameHandler{}})
-	case "key":
-		args.state.push(&tagParserHandlerWrapper{inner: &tagParserKeyHandler{}})
-	case "format":
-		args.state.push(&tagParserHandlerWrapper{inner: &tagParserFormatHandler{}})
-	case "autoinc":
-		args.state.push(&tagParserHandlerWrapper{inner: &tagParserAutoincHandler{}})
 	default:
+		if newHandler, ok := builtinKeywords[args.text]; ok {
+			args.state.addKeyword(args.text)
+			args.state.push(&tagParserHandlerWrapper{inner: newHandler(&args.state.tag)})
+			return
+		}
+		if fn, ok := findKeyword(args.text); ok {
+			args.state.addKeyword(args.text)
+			args.state.push(&tagParserHandlerWrapper{inner: &tagParserRegisteredHandler{name: args.text, fn: fn}})
+			return
+		}
 		args.state.eb.AddError(fmt.Errorf("Unknown token \"%v\"", args.text))
 	}
 }
 
+// builtinKeywords answers a new handler for each keyword the
+// parser handles itself. Keywords without arguments set their
+// value on the tag when they're found.
+var builtinKeywords = map[string]func(*Tag) tagParserHandler{
+	"name":    func(*Tag) tagParserHandler { return &tagParserNameHandler{} },
+	"key":     func(*Tag) tagParserHandler { return &tagParserKeyHandler{} },
+	"format":  func(*Tag) tagParserHandler { return &tagParserFormatHandler{} },
+	"autoinc": func(*Tag) tagParserHandler { return &tagParserAutoincHandler{} },
+	"index":   func(*Tag) tagParserHandler { return &tagParserIndexHandler{} },
+	"unique":  func(*Tag) tagParserHandler { return &tagParserUniqueHandler{} },
+	"type":    func(*Tag) tagParserHandler { return &tagParserTypeHandler{} },
+	"size":    func(*Tag) tagParserHandler { return &tagParserSizeHandler{} },
+	"default": func(*Tag) tagParserHandler { return &tagParserDefaultHandler{} },
+	"notnull": func(t *Tag) tagParserHandler {
+		t.NotNull = true
+		return &tagParserEmptyHandler{}
+	},
+	"nullable": func(t *Tag) tagParserHandler {
+		t.Nullable = true
+		return &tagParserEmptyHandler{}
+	},
+	"created": func(t *Tag) tagParserHandler {
+		t.Created = true
+		return &tagParserEmptyHandler{}
+	},
+	"updated": func(t *Tag) tagParserHandler {
+		t.Updated = true
+		return &tagParserEmptyHandler{}
+	},
+	"version": func(t *Tag) tagParserHandler {
+		t.Version = true
+		return &tagParserEmptyHandler{}
+	},
+	"inline": func(t *Tag) tagParserHandler {
+		t.Inline = true
+		return &tagParserEmptyHandler{}
+	},
+	"flatten": func(*Tag) tagParserHandler { return &tagParserFlattenHandler{} },
+	"naming":  func(*Tag) tagParserHandler { return &tagParserNamingHandler{} },
+	"was":     func(*Tag) tagParserHandler { return &tagParserWasHandler{} },
+	"encrypt": func(t *Tag) tagParserHandler {
+		t.Encrypt = true
+		return &tagParserEmptyHandler{}
+	},
+	"compress": func(*Tag) tagParserHandler { return &tagParserCompressHandler{} },
+}
+
 // tagParserNameHandler handles the name.
 type tagParserNameHandler struct {
 }
@@ -186,6 +408,106 @@ func (h *tagParserFormatHandler) Handle(args tagParserArgs) {
 	}
 }
 
+// tagParserFlattenHandler handles the flatten prefix.
+type tagParserFlattenHandler struct {
+}
+
+func (h *tagParserFlattenHandler) Start(*tagParserState) {
+}
+
+func (h *tagParserFlattenHandler) End(s *tagParserState) {
+	if s.tag.Flatten == "" {
+		s.eb.AddError(fmt.Errorf("Flatten requires a prefix"))
+	}
+}
+
+func (h *tagParserFlattenHandler) Handle(args tagParserArgs) {
+	switch args.text {
+	case ")":
+		args.state.pop()
+	default:
+		if args.state.tag.Flatten == "" {
+			args.state.tag.Flatten = args.text
+		}
+	}
+}
+
+// tagParserNamingHandler handles the naming strategy.
+type tagParserNamingHandler struct {
+}
+
+func (h *tagParserNamingHandler) Start(*tagParserState) {
+}
+
+func (h *tagParserNamingHandler) End(s *tagParserState) {
+	if s.tag.Naming == "" {
+		s.eb.AddError(fmt.Errorf("Naming requires a strategy"))
+	} else {
+		s.eb.AddError(CheckNaming(s.tag.Naming))
+	}
+}
+
+func (h *tagParserNamingHandler) Handle(args tagParserArgs) {
+	switch args.text {
+	case ")":
+		args.state.pop()
+	default:
+		if args.state.tag.Naming == "" {
+			args.state.tag.Naming = args.text
+		}
+	}
+}
+
+// tagParserCompressHandler handles the compression algorithm.
+type tagParserCompressHandler struct {
+}
+
+func (h *tagParserCompressHandler) Start(*tagParserState) {
+}
+
+func (h *tagParserCompressHandler) End(s *tagParserState) {
+	switch {
+	case s.tag.Compress == "":
+		s.eb.AddError(fmt.Errorf("Compress requires an algorithm"))
+	case !slices.Contains(compressions, s.tag.Compress):
+		s.eb.AddError(fmt.Errorf("Unknown compression \"%v\", must be one of %v", s.tag.Compress, strings.Join(compressions, ", ")))
+	}
+}
+
+func (h *tagParserCompressHandler) Handle(args tagParserArgs) {
+	switch args.text {
+	case ")":
+		args.state.pop()
+	default:
+		if args.state.tag.Compress == "" {
+			args.state.tag.Compress = args.text
+		}
+	}
+}
+
+// tagParserWasHandler handles the previous names.
+type tagParserWasHandler struct {
+}
+
+func (h *tagParserWasHandler) Start(*tagParserState) {
+}
+
+func (h *tagParserWasHandler) End(s *tagParserState) {
+	if len(s.tag.Was) < 1 {
+		s.eb.AddError(fmt.Errorf("Was requires a name"))
+	}
+}
+
+func (h *tagParserWasHandler) Handle(args tagParserArgs) {
+	switch args.text {
+	case ")":
+		args.state.pop()
+	case ",":
+	default:
+		args.state.tag.Was = append(args.state.tag.Was, args.text)
+	}
+}
+
 // tagParserKeyHandler handles the key.
 type tagParserKeyHandler struct {
 	idx int
@@ -221,6 +543,209 @@ func (h *tagParserKeyHandler) Handle(args tagParserArgs) {
 	}
 }
 
+// tagParserIndexHandler handles the index.
+type tagParserIndexHandler struct {
+	idx   int
+	index IndexTag
+}
+
+func (h *tagParserIndexHandler) Start(*tagParserState) {
+}
+
+func (h *tagParserIndexHandler) End(s *tagParserState) {
+	s.tag.Indexes = append(s.tag.Indexes, h.index)
+}
+
+func (h *tagParserIndexHandler) Handle(args tagParserArgs) {
+	switch args.text {
+	case ")":
+		args.state.pop()
+	case ",":
+		h.idx++
+	case "unique":
+		if h.idx < 1 {
+			args.state.eb.AddError(fmt.Errorf("Index unique must follow the name"))
+		}
+		h.index.Unique = true
+	default:
+		switch h.idx {
+		case 0:
+			h.index.Name = args.text
+		case 1:
+			i, err := strconv.Atoi(args.text)
+			if err != nil {
+				args.state.eb.AddError(fmt.Errorf("illegal index position \"%v\"", args.text))
+			} else {
+				h.index.Index = i
+			}
+		default:
+			args.state.eb.AddError(fmt.Errorf("Index position %v too high on token \"%v\"", h.idx, args.text))
+		}
+	}
+}
+
+// tagParserUniqueHandler handles the unique.
+type tagParserUniqueHandler struct {
+	name string
+}
+
+func (h *tagParserUniqueHandler) Start(*tagParserState) {
+}
+
+func (h *tagParserUniqueHandler) End(s *tagParserState) {
+	if !slices.Contains(s.tag.Uniques, h.name) {
+		s.tag.Uniques = append(s.tag.Uniques, h.name)
+	}
+}
+
+func (h *tagParserUniqueHandler) Handle(args tagParserArgs) {
+	switch args.text {
+	case ")":
+		args.state.pop()
+	default:
+		if h.name != "" {
+			args.state.eb.AddError(fmt.Errorf("Unique takes a single name on token \"%v\"", args.text))
+		}
+		h.name = args.text
+	}
+}
+
+// tagParserDefaultHandler handles the default. The value is
+// sliced from the expression, so it can be scanned as several
+// tokens, like -1 or 24h. A quoted string is unquoted, which
+// allows values with commas or parens.
+type tagParserDefaultHandler struct {
+	start, end int
+	quoted     bool
+	closed     bool
+}
+
+func (h *tagParserDefaultHandler) Start(*tagParserState) {
+	h.start = -1
+}
+
+func (h *tagParserDefaultHandler) End(s *tagParserState) {
+	if !h.closed {
+		s.eb.AddError(fmt.Errorf("Default requires a value"))
+	}
+}
+
+func (h *tagParserDefaultHandler) Handle(args tagParserArgs) {
+	switch args.text {
+	case ")":
+		h.setDefault(args.state)
+		args.state.pop()
+	default:
+		if h.start < 0 {
+			h.start = args.offset
+			h.quoted = args.token == scanner.String || args.token == scanner.RawString
+		} else {
+			h.quoted = false
+		}
+		h.end = args.offset + len(args.text)
+	}
+}
+
+func (h *tagParserDefaultHandler) setDefault(s *tagParserState) {
+	h.closed = true
+	s.tag.HasDefault = true
+	if h.start < 0 {
+		return
+	}
+	v := strings.TrimSpace(s.expr[h.start:h.end])
+	if h.quoted {
+		uq, err := strconv.Unquote(v)
+		if err != nil {
+			s.eb.AddError(fmt.Errorf("illegal default %v", v))
+		}
+		v = uq
+	}
+	s.tag.Default = v
+}
+
+// tagParserTypeHandler handles the type. The type is sliced
+// from the expression, and can have its own parens, i.e.
+// type(DECIMAL(10, 2)).
+type tagParserTypeHandler struct {
+	start, end int
+	depth      int
+}
+
+func (h *tagParserTypeHandler) Start(*tagParserState) {
+	h.start = -1
+}
+
+func (h *tagParserTypeHandler) End(s *tagParserState) {
+	if s.tag.Type == "" {
+		s.eb.AddError(fmt.Errorf("Type requires a value"))
+	}
+}
+
+func (h *tagParserTypeHandler) Handle(args tagParserArgs) {
+	switch {
+	case args.text == ")" && h.depth < 1:
+		if h.start >= 0 {
+			args.state.tag.Type = strings.TrimSpace(args.state.expr[h.start:h.end])
+		}
+		args.state.pop()
+		return
+	case args.text == "(":
+		h.depth++
+	case args.text == ")":
+		h.depth--
+	}
+	if h.start < 0 {
+		h.start = args.offset
+	}
+	h.end = args.offset + len(args.text)
+}
+
+// tagParserSizeHandler handles the size.
+type tagParserSizeHandler struct {
+}
+
+func (h *tagParserSizeHandler) Start(*tagParserState) {
+}
+
+func (h *tagParserSizeHandler) End(s *tagParserState) {
+	if s.tag.Size < 1 {
+		s.eb.AddError(fmt.Errorf("Size requires a positive value"))
+	}
+}
+
+func (h *tagParserSizeHandler) Handle(args tagParserArgs) {
+	switch args.text {
+	case ")":
+		args.state.pop()
+	default:
+		i, err := strconv.Atoi(args.text)
+		if err != nil {
+			args.state.eb.AddError(fmt.Errorf("illegal size \"%v\"", args.text))
+		} else {
+			args.state.tag.Size = i
+		}
+	}
+}
+
+// tagParserEmptyHandler handles keywords without arguments.
+type tagParserEmptyHandler struct {
+}
+
+func (h *tagParserEmptyHandler) Start(*tagParserState) {
+}
+
+func (h *tagParserEmptyHandler) End(*tagParserState) {
+}
+
+func (h *tagParserEmptyHandler) Handle(args tagParserArgs) {
+	switch args.text {
+	case ")":
+		args.state.pop()
+	default:
+		args.state.eb.AddError(fmt.Errorf("Unexpected argument \"%v\"", args.text))
+	}
+}
+
 // tagParserAutoincHandler handles the autoinc.
 type tagParserAutoincHandler struct {
 	flag Flags
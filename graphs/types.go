@@ -3,4 +3,8 @@ package graphs
 type Entry struct {
 	// Graph answers the graph for this entry.
 	Graph StringFunc
+
+	// Hidden entries are only run from code, so they
+	// are not presented to users.
+	Hidden bool
 }
@@ -0,0 +1,219 @@
+package drivers
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hackborn/doc_drivers/enc"
+	"github.com/hackborn/doc_drivers/nodes"
+	"github.com/hackborn/doc_drivers/registry"
+	"github.com/hackborn/doc_drivers/schema"
+)
+
+// Lint checks the domain structs against the rules of every
+// target without generating anything. If the settings have no
+// format or targets, every registered backend is checked.
+// Problems in the domain are answered as findings. The error
+// is for problems running the lint, such as a missing config.
+func Lint(settings MakeDriverSettings) ([]LintFinding, error) {
+	if settings.ConfigPath != "" {
+		c, err := ReadConfig(settings.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		settings, err = c.apply(settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	targets := lintTargets(settings)
+	factories := make([]registry.Factory, len(targets))
+	for i, t := range targets {
+		f, err := registry.Open(t.Format)
+		if err != nil {
+			return nil, t.wrapErr(i, err)
+		}
+		factories[i] = f
+	}
+
+	structs, err := loadStructs(settings)
+	if err != nil {
+		return nil, err
+	}
+	sources := newSourceIndex(settings.LoadGlob, settings.LoadSeparator)
+	structs, err = applyOverrides(structs, settings.Structs)
+	if err != nil {
+		return lintErrors("", sources, err), nil
+	}
+
+	var findings []LintFinding
+	for i, t := range targets {
+		// The package name doesn't matter to the domain.
+		t.Pkg = cmp.Or(t.Pkg, "lint")
+		output, err := runTarget(factories[i], t, settings, nodes.SaveModeMemory, structs)
+		if err != nil {
+			findings = append(findings, lintErrors(t.Format, sources, err)...)
+			continue
+		}
+		result := newMakeDriverResult(output)
+		findings = append(findings, lintSharedTables(t.Format, sources, result.Schema)...)
+	}
+	slices.SortStableFunc(findings, func(a, b LintFinding) int {
+		return cmp.Or(strings.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+	return findings, nil
+}
+
+// lintTargets answers the targets in the settings,
+// or every backend if there are none.
+func lintTargets(settings MakeDriverSettings) []TargetSettings {
+	if len(settings.Targets) > 0 || settings.Format != "" {
+		return settings.targets()
+	}
+	names := registry.Names()
+	slices.Sort(names)
+	targets := make([]TargetSettings, 0, len(names))
+	for _, name := range names {
+		targets = append(targets, TargetSettings{Format: name, Flags: settings.Flags})
+	}
+	return targets
+}
+
+// lintErrors answers a finding for every field error in err,
+// and one for each error beside them.
+func lintErrors(target string, sources sourceIndex, err error) []LintFinding {
+	var findings []LintFinding
+	errs := []error{sources.locate(err)}
+	if joined, ok := errs[0].(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		if fe, ok := err.(*enc.FieldError); ok {
+			findings = append(findings, LintFinding{Severity: LintError,
+				Target: target,
+				Struct: fe.Struct,
+				Field:  fe.Field,
+				File:   fe.File,
+				Line:   fe.Line,
+				Msg:    fe.Err.Error(),
+			})
+		} else {
+			findings = append(findings, LintFinding{Severity: LintError, Target: target, Msg: err.Error()})
+		}
+	}
+	return findings
+}
+
+// lintSharedTables compares the structs that share a table.
+// A column with different types is an error, since the table
+// can only hold one. A column that only some of the structs
+// have is a warning.
+func lintSharedTables(target string, sources sourceIndex, s schema.Schema) []LintFinding {
+	var findings []LintFinding
+	first := make(map[string]schema.Table)
+	for _, table := range s.Tables {
+		prev, ok := first[table.Name]
+		if !ok {
+			first[table.Name] = table
+			continue
+		}
+		add := func(severity LintSeverity, field, msg string, args ...any) {
+			f := LintFinding{Severity: severity, Target: target, Struct: table.Struct, Field: field, Msg: fmt.Sprintf(msg, args...)}
+			f.File, f.Line = sources.position(f.Struct, f.Field)
+			findings = append(findings, f)
+		}
+		for _, col := range table.Columns {
+			idx := slices.IndexFunc(prev.Columns, func(c schema.Column) bool {
+				return c.Name == col.Name
+			})
+			switch {
+			case idx < 0:
+				add(LintWarning, col.Field, "Column \"%v\" is in table \"%v\" but not in %v", col.Name, table.Name, prev.Struct)
+			case prev.Columns[idx].DbType != col.DbType:
+				add(LintError, col.Field, "Column \"%v\" is %v but %v in %v, which shares table \"%v\"", col.Name, col.DbType, prev.Columns[idx].DbType, prev.Struct, table.Name)
+			}
+		}
+		for _, col := range prev.Columns {
+			if !slices.ContainsFunc(table.Columns, func(c schema.Column) bool {
+				return c.Name == col.Name
+			}) {
+				add(LintWarning, "", "Column \"%v\" is in table \"%v\" for %v but not here", col.Name, table.Name, prev.Struct)
+			}
+		}
+	}
+	return findings
+}
+
+// LintFinding is a single problem reported by Lint.
+type LintFinding struct {
+	Severity LintSeverity
+
+	// Target is the format of the backend that reported
+	// the finding, or empty if it applies to all of them.
+	Target string
+
+	// Struct and Field locate the finding in the domain. Field
+	// is empty for findings on the whole struct.
+	Struct string
+	Field  string
+
+	// File and Line are the source position, if known.
+	File string
+	Line int
+
+	Msg string
+}
+
+// String answers the finding in the form
+// "file:line: severity: target: Struct.Field: message".
+func (f LintFinding) String() string {
+	var parts []string
+	if f.File != "" {
+		parts = append(parts, fmt.Sprintf("%v:%v", f.File, f.Line))
+	}
+	parts = append(parts, f.Severity.String())
+	if f.Target != "" {
+		parts = append(parts, f.Target)
+	}
+	if f.Struct != "" {
+		name := f.Struct
+		if f.Field != "" {
+			name += "." + f.Field
+		}
+		parts = append(parts, name)
+	}
+	return strings.Join(append(parts, f.Msg), ": ")
+}
+
+// LintErrors answers the findings that are errors, joined,
+// or nil if there are only warnings.
+func LintErrors(findings []LintFinding) error {
+	var errs []error
+	for _, f := range findings {
+		if f.Severity == LintError {
+			errs = append(errs, errors.New(f.String()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type LintSeverity int
+
+const (
+	LintError LintSeverity = iota
+	LintWarning
+)
+
+func (s LintSeverity) String() string {
+	switch s {
+	case LintError:
+		return "error"
+	case LintWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
@@ -1,37 +1,183 @@
 package drivers
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hackborn/doc_drivers/nodes"
 	"github.com/hackborn/doc_drivers/registry"
+	"github.com/hackborn/doc_drivers/schema"
 	"github.com/hackborn/onefunc/pipeline"
 )
 
-// MakeDriver generates a new driver with the supplied settings.
+// MakeDriver generates a new driver for every target in the settings.
 func MakeDriver(settings MakeDriverSettings) error {
-	f, err := registry.Open(settings.Format)
+	_, err := makeDrivers(settings, settings.saveMode())
+	return err
+}
+
+// MakeDriverToMemory generates a new driver for every target in
+// the settings, answering the results instead of saving them.
+// SavePath and Check are ignored.
+func MakeDriverToMemory(settings MakeDriverSettings) (MakeDriverResult, error) {
+	results, err := makeDrivers(settings, nodes.SaveModeMemory)
+	result := MakeDriverResult{Targets: results}
+	if len(results) == 1 {
+		result.Files = results[0].Files
+		result.Schema = results[0].Schema
+	}
+	return result, err
+}
+
+// makeDrivers loads and parses the domain structs once, then
+// generates every target from the shared parse. Errors are
+// grouped by target.
+func makeDrivers(settings MakeDriverSettings, saveMode string) ([]MakeDriverResult, error) {
+	if settings.ConfigPath != "" {
+		c, err := ReadConfig(settings.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		settings, err = c.apply(settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	targets := settings.targets()
+	if len(targets) < 1 {
+		return nil, fmt.Errorf("no targets")
+	}
+	// Open every backend before doing any work.
+	var errs []error
+	factories := make([]registry.Factory, len(targets))
+	for i, t := range targets {
+		f, err := registry.Open(t.Format)
+		errs = append(errs, t.wrapErr(i, err))
+		factories[i] = f
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	structs, err := loadStructs(settings)
+	if err != nil {
+		return nil, err
+	}
+	// Domain errors are reported with their source position.
+	var sources sourceIndex
+	locate := func(err error) error {
+		if sources == nil {
+			sources = newSourceIndex(settings.LoadGlob, settings.LoadSeparator)
+		}
+		return sources.locate(err)
+	}
+
+	structs, err = applyOverrides(structs, settings.Structs)
 	if err != nil {
-		return err
+		return nil, locate(err)
 	}
-	graph, err := f.Graph("Make driver")
+
+	errs = errs[:0]
+	results := make([]MakeDriverResult, len(targets))
+	for i, t := range targets {
+		output, err := runTarget(factories[i], t, settings, saveMode, structs)
+		if err != nil {
+			errs = append(errs, t.wrapErr(i, locate(err)))
+			continue
+		}
+		results[i] = newMakeDriverResult(output)
+	}
+	return results, errors.Join(errs...)
+}
+
+// loadStructs answers the parsed domain structs.
+func loadStructs(settings MakeDriverSettings) ([]pipeline.Pin, error) {
+	const graph = `graph ( load(Glob=$load, Separator=$loadsep) -> docstruct(Tag="doc") -> structfilter(Include=$include, Exclude=$exclude) )`
+	env := map[string]any{
+		"$load":    settings.LoadGlob,
+		"$loadsep": settings.LoadSeparator,
+		"$include": filterExpr(settings.Include),
+		"$exclude": filterExpr(settings.Exclude),
+	}
+	output, err := pipeline.RunExpr(graph, nil, env)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return output.Pins, nil
+}
+
+// runTarget generates a single target from the parsed structs.
+func runTarget(f registry.Factory, t TargetSettings, settings MakeDriverSettings, saveMode string, structs []pipeline.Pin) (*pipeline.RunOutput, error) {
+	graph, err := f.Graph("Generate driver")
+	if err != nil {
+		return nil, err
+	}
+	// Every target gets its own copy of the structs.
+	input := &pipeline.RunInput{Pins: make([]pipeline.Pin, 0, len(structs))}
+	for _, pin := range structs {
+		pin.Payload = pin.Payload.Clone()
+		input.Pins = append(input.Pins, pin)
 	}
 	env := map[string]any{
-		"$load":        settings.LoadGlob,
-		"$loadsep":     settings.LoadSeparator,
-		"$save":        settings.SavePath,
-		"$pkg":         settings.Pkg,
-		"$prefix":      settings.Prefix,
+		"$include":     filterExpr(t.Include),
+		"$exclude":     filterExpr(t.Exclude),
+		"$save":        t.SavePath,
+		"$savemode":    saveMode,
+		"$pkg":         t.Pkg,
+		"$prefix":      t.Prefix,
 		"$tableprefix": "",
 		"$droptables":  false,
-		"$flags":       settings.makeFlags(),
+		"$flags":       t.makeFlags(),
+		"$naming":      settings.Naming,
+		"$inputhash":   settings.InputHash,
 	}
-	_, err = pipeline.RunExpr(graph, nil, env)
-	return err
+	return pipeline.RunExpr(graph, input, env)
+}
+
+// MakeDriverResult is the in-memory result of generating a driver.
+type MakeDriverResult struct {
+	// Files maps each generated file name to its contents.
+	Files map[string][]byte
+
+	// Schema describes the tables, columns and keys
+	// the driver was generated from.
+	Schema schema.Schema
+
+	// Targets contains a result for every target, in order.
+	// If there is a single target, Files and Schema are
+	// its results.
+	Targets []MakeDriverResult
+}
+
+func newMakeDriverResult(output *pipeline.RunOutput) MakeDriverResult {
+	result := MakeDriverResult{Files: make(map[string][]byte)}
+	for _, pin := range output.Pins {
+		switch p := pin.Payload.(type) {
+		case *pipeline.ContentData:
+			result.Files[p.Name] = []byte(p.Data)
+		case *schema.Schema:
+			result.Schema.Format = p.Format
+			result.Schema.Tables = append(result.Schema.Tables, p.Tables...)
+		}
+	}
+	return result
 }
 
 type MakeDriverSettings struct {
+	// ConfigPath is an optional path to a JSON config file (see
+	// Config). Settings in the config are used for any values
+	// that aren't set here.
+	ConfigPath string
+
+	// Targets are the drivers to generate. Each target is
+	// generated from the same domain structs. If there are no
+	// targets, then Format, SavePath, Pkg, Prefix and Flags
+	// describe a single target.
+	Targets []TargetSettings
+
 	// The desired storage format for the driver. Currently supported:
-	// "sqlite"
+	// "sqlite", "bbolt"
 	Format string
 
 	// LoadGlob is a glob to a folder containing
@@ -47,6 +193,15 @@ type MakeDriverSettings struct {
 	// as two separate paths and the results combined.
 	LoadSeparator string
 
+	// Include is a list of struct names or regular expressions.
+	// If set, only structs that match are included in the drivers.
+	Include []string
+
+	// Exclude is a list of struct names or regular expressions.
+	// Structs that match are left out of the drivers, the same
+	// as if they had a `doc:"-"` table tag.
+	Exclude []string
+
 	// SavePath is a filepath to a folder where the new
 	// driver will be saved.
 	SavePath string
@@ -59,11 +214,105 @@ type MakeDriverSettings struct {
 
 	// Flags is a list of per-driver named flags.
 	Flags []string
+
+	// Naming converts the names of structs and fields that
+	// don't have a name tag to table, column, bucket and JSON
+	// names: "snake_case", "lower", "camel" or "verbatim". A
+	// table tag of naming(strategy) overrides it for a single
+	// struct. If empty, each backend uses its own convention.
+	Naming string
+
+	// Check runs the generator without writing anything. Instead,
+	// the output is compared to the driver already in SavePath,
+	// and a *StaleError is returned if any files would be
+	// added, changed or deleted.
+	Check bool
+
+	// InputHash adds a hash of the inputs (the domain structs
+	// and the settings that affect the output) to the header of
+	// every generated file. Identical inputs always produce
+	// identical output, so the hash only changes when a
+	// regeneration actually changed something.
+	InputHash bool
+
+	// Structs are overrides for the domain structs, by struct name.
+	Structs map[string]StructOverride
+}
+
+// StaleError is returned from a Check when the generated
+// driver differs from the one in SavePath.
+type StaleError = nodes.StaleError
+
+func (s MakeDriverSettings) saveMode() string {
+	if s.Check {
+		return nodes.SaveModeCheck
+	}
+	return nodes.SaveModeSave
+}
+
+func (s MakeDriverSettings) targets() []TargetSettings {
+	if len(s.Targets) > 0 {
+		return s.Targets
+	}
+	return []TargetSettings{{
+		Format:   s.Format,
+		SavePath: s.SavePath,
+		Pkg:      s.Pkg,
+		Prefix:   s.Prefix,
+		Flags:    s.Flags,
+	}}
+}
+
+// TargetSettings describes a single driver to generate.
+type TargetSettings struct {
+	// The desired storage format for the driver.
+	Format string `json:"format"`
+
+	// SavePath is a filepath to a folder where the new
+	// driver will be saved.
+	SavePath string `json:"save"`
+
+	// Pkg is the name of the package to use for the new driver.
+	Pkg string `json:"pkg"`
+
+	// Prefix is the prefix name to use for the driver types.
+	Prefix string `json:"prefix"`
+
+	// Flags is a list of per-driver named flags.
+	Flags []string `json:"flags"`
+
+	// Include and Exclude filter the structs for this
+	// target only. See MakeDriverSettings.
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// wrapErr identifies the target in the error. Stale errors
+// are reported without the pipeline wrapping.
+func (t TargetSettings) wrapErr(index int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var stale *StaleError
+	if errors.As(err, &stale) {
+		err = stale
+	}
+	return fmt.Errorf("target %v (%v): %w", index, t.Format, err)
+}
+
+// filterExpr answers a regular expression that matches
+// the whole of any of the patterns, or an empty string
+// if there are no patterns.
+func filterExpr(patterns []string) string {
+	if len(patterns) < 1 {
+		return ""
+	}
+	return "^(?:" + strings.Join(patterns, "|") + ")$"
 }
 
-func (s MakeDriverSettings) makeFlags() string {
+func (t TargetSettings) makeFlags() string {
 	f := ""
-	for _, flag := range s.Flags {
+	for _, flag := range t.Flags {
 		if flag == "" {
 			continue
 		}
@@ -0,0 +1,505 @@
+package drivers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hackborn/doc_drivers/enc"
+	"github.com/hackborn/doc_drivers/registry"
+	"github.com/hackborn/doc_drivers/schema"
+	"github.com/hackborn/onefunc/jacl"
+)
+
+// ---------------------------------------------------------
+// TEST-MAKE-DRIVER-TO-MEMORY
+func TestMakeDriverToMemory(t *testing.T) {
+	settings := MakeDriverSettings{
+		Format:        "bbolt",
+		LoadGlob:      "domain/*;domain2/*",
+		LoadSeparator: ";",
+		Pkg:           "bboltgendriver",
+		Prefix:        "gen",
+		Flags:         []string{"lowercase"},
+	}
+	have, err := MakeDriverToMemory(settings)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	for _, name := range []string{"gen_const.go", "gen_driver.go", "gen_fn.go", "gen_json.go", "gen_metadata.go", "schema.json"} {
+		if len(have.Files[name]) < 1 {
+			t.Fatalf("Missing file %v", name)
+		}
+	}
+	want := []string{`Format=bbolt`,
+		`Tables/0/Struct=CollectionSetting`,
+		`Tables/4/Struct=Events`,
+		`Tables/4/Columns/0/DbType=uint64`,
+		`Tables/4/Columns/0/Flags/1=autoinc`,
+		`Tables/4/Columns/2/DbType=json`,
+		`Tables/4/Keys/0/Columns/0=name`,
+		`Tables/4/Keys/0/Columns/1=time`,
+		`Tables/6/Struct=Filing`,
+		`Tables/6/Name=filing`,
+		`Tables/6/Keys/0/Columns/0=ticker`,
+		`Tables/6/Keys/0/Columns/1=end`,
+		`Tables/6/Columns/3/Field=Value`,
+		`Tables/6/Columns/3/Name=val`,
+		`Tables/6/Columns/4/Default=usd`,
+		`Tables/6/Columns/4/Flags/0=notnull`,
+		`Tables/6/Indexes/0/Name=fy`,
+		`Tables/6/Indexes/0/Columns/0=fy`,
+		`Tables/3/Struct=Document`,
+		`Tables/3/Columns/2/Flags/0=version`,
+		`Tables/3/Columns/1/Was/0=text`,
+		`Tables/1/Uniques/0/Name=ticker`,
+		`Tables/1/Uniques/0/Columns/0=ticker`,
+		`Tables/1/Indexes/{count}=0`,
+		`Tables/2/Struct=Contact`,
+		`Tables/2/Columns/0/Field="Record.Id"`,
+		`Tables/2/Columns/0/Name=id`,
+		`Tables/2/Columns/3/Field="Home.City"`,
+		`Tables/2/Columns/3/Name=home_city`,
+		`Tables/2/Indexes/0/Columns/0=home_city`,
+		`Tables/2/Columns/4/Name=phone`,
+		`Tables/2/Columns/4/Format=json`,
+		`Tables/2/Columns/4/Flags/0=encrypt`,
+	}
+	if err := jacl.Run(&have.Schema, want...); err != nil {
+		t.Fatalf("Has schema %v (%v)", have.Schema, err)
+	}
+	// The manifest is the same schema.
+	var manifest schema.Schema
+	if err := json.Unmarshal(have.Files["schema.json"], &manifest); err != nil {
+		t.Fatalf("Has manifest err %v", err)
+	}
+	if !reflect.DeepEqual(manifest, have.Schema) {
+		t.Fatalf("Has manifest %v but want %v", manifest, have.Schema)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-MAKE-DRIVER-REPRODUCIBLE
+func TestMakeDriverReproducible(t *testing.T) {
+	settings := MakeDriverSettings{
+		Format:        "bbolt",
+		LoadGlob:      "domain/*;domain2/*",
+		LoadSeparator: ";",
+		Pkg:           "bboltgendriver",
+		Prefix:        "gen",
+		InputHash:     true,
+	}
+	a, err := MakeDriverToMemory(settings)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	b, err := MakeDriverToMemory(settings)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	if len(a.Files) != len(b.Files) {
+		t.Fatalf("Has %v files but want %v", len(b.Files), len(a.Files))
+	}
+	for name, content := range a.Files {
+		if !bytes.Equal(content, b.Files[name]) {
+			t.Fatalf("File %v differs between runs", name)
+		}
+		// The hash is a header comment, so only Go files have one.
+		if filepath.Ext(name) == ".go" && !bytes.Contains(content, []byte(registry.InputHashPrefix+"sha256:")) {
+			t.Fatalf("File %v is missing the input hash", name)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-MAKE-DRIVER-INPUT-HASH-FLAGS
+func TestMakeDriverInputHashFlags(t *testing.T) {
+	f := func(format string) {
+		t.Helper()
+
+		settings := MakeDriverSettings{
+			Format:    format,
+			LoadGlob:  "domain/company.go",
+			Pkg:       "gendriver",
+			Prefix:    "gen",
+			InputHash: true,
+		}
+		a, err := MakeDriverToMemory(settings)
+		if err != nil {
+			t.Fatalf("Has err %v", err)
+		}
+		settings.Flags = []string{"testflag"}
+		b, err := MakeDriverToMemory(settings)
+		if err != nil {
+			t.Fatalf("Has err %v", err)
+		}
+		for name, content := range a.Files {
+			if filepath.Ext(name) == ".go" && bytes.Equal(content, b.Files[name]) {
+				t.Fatalf("%v file %v has the same input hash with different flags", format, name)
+			}
+		}
+	}
+	f("sqlite")
+	f("bbolt")
+}
+
+// ---------------------------------------------------------
+// TEST-MAKE-DRIVER-TARGETS
+func TestMakeDriverTargets(t *testing.T) {
+	settings := MakeDriverSettings{
+		LoadGlob:      "domain/filing.go;domain/company.go",
+		LoadSeparator: ";",
+		Targets: []TargetSettings{
+			{Format: "sqlite", Pkg: "sqlitegendriver", Prefix: "gen"},
+			{Format: "bbolt", Pkg: "bboltgendriver", Prefix: "gen", Flags: []string{"lowercase"}},
+		},
+	}
+	have, err := MakeDriverToMemory(settings)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	if len(have.Targets) != 2 {
+		t.Fatalf("Has %v targets but wants 2", len(have.Targets))
+	}
+	if len(have.Targets[0].Files["gen_sql.go"]) < 1 {
+		t.Fatalf("Missing sqlite file gen_sql.go")
+	}
+	if len(have.Targets[1].Files["gen_json.go"]) < 1 {
+		t.Fatalf("Missing bbolt file gen_json.go")
+	}
+	want := []string{`Targets/0/Schema/Tables/0/Struct=Company`,
+		`Targets/0/Schema/Tables/1/Struct=Filing`,
+		`Targets/1/Schema/Tables/0/Struct=Company`,
+		`Targets/1/Schema/Tables/1/Struct=Filing`,
+	}
+	if err := jacl.Run(&have, want...); err != nil {
+		t.Fatalf("Has %v (%v)", have, err)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-MAKE-DRIVER-NAMING
+func TestMakeDriverNaming(t *testing.T) {
+	settings := MakeDriverSettings{
+		LoadGlob: "testdata/naming.go",
+		Naming:   "snake_case",
+		Targets: []TargetSettings{
+			{Format: "sqlite", Pkg: "sqlitegendriver", Prefix: "gen"},
+			{Format: "bbolt", Pkg: "bboltgendriver", Prefix: "gen", Flags: []string{"lowercase"}},
+		},
+	}
+	have, err := MakeDriverToMemory(settings)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	var want []string
+	for i := range settings.Targets {
+		target := "Targets/" + strconv.Itoa(i) + "/Schema/Tables/"
+		want = append(want, target+`0/Name=accountEvent`,
+			target+`0/Columns/0/Name=eventID`,
+			target+`1/Name=user_account`,
+			target+`1/Columns/0/Name=user_id`,
+			target+`1/Columns/1/Name=founded_year`,
+			target+`1/Columns/2/Name=nick`,
+		)
+	}
+	if err := jacl.Run(&have, want...); err != nil {
+		t.Fatalf("Has %v (%v)", have, err)
+	}
+	// bbolt columns are named by the json tag the record is stored under.
+	bbolt := have.Targets[1]
+	for _, table := range bbolt.Schema.Tables {
+		for _, col := range table.Columns {
+			if slices.Contains(col.Flags, "key") {
+				continue
+			}
+			if tag := `json:"` + col.Name + `"`; !bytes.Contains(bbolt.Files["gen_json.go"], []byte(tag)) {
+				t.Fatalf("Has column %v.%v but no json tag %v", table.Name, col.Name, tag)
+			}
+		}
+	}
+	settings.Naming = "kebab"
+	if _, err := MakeDriverToMemory(settings); err == nil {
+		t.Fatalf("Has no error for an unknown naming")
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-MAKE-DRIVER-COMPRESS
+func TestMakeDriverCompress(t *testing.T) {
+	settings := MakeDriverSettings{
+		Format:   "bbolt",
+		LoadGlob: "testdata/compress.go",
+		Pkg:      "bboltgendriver",
+		Prefix:   "gen",
+	}
+	have, err := MakeDriverToMemory(settings)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	want := []string{`Tables/0/Struct=Archive`,
+		`Tables/0/Compress=gzip`,
+		`Tables/0/Columns/1/Compress=flate`,
+		`Tables/0/Columns/1/Format=json`,
+	}
+	if err := jacl.Run(&have.Schema, want...); err != nil {
+		t.Fatalf("Has schema %v (%v)", have.Schema, err)
+	}
+	settings.Format, settings.Pkg = "sqlite", "sqlitegendriver"
+	_, err = MakeDriverToMemory(settings)
+	if err == nil || !strings.Contains(err.Error(), "Table compress is not supported by sqlite") {
+		t.Fatalf("Has err %v but wants table compress error", err)
+	}
+	// Every backend compresses fields.
+	settings = MakeDriverSettings{
+		LoadGlob: "testdata/note.go",
+		Targets: []TargetSettings{
+			{Format: "sqlite", Pkg: "sqlitegendriver", Prefix: "gen"},
+			{Format: "bbolt", Pkg: "bboltgendriver", Prefix: "gen"},
+		},
+	}
+	have, err = MakeDriverToMemory(settings)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	want = []string{`Targets/0/Schema/Tables/0/Columns/1/Compress=gzip`,
+		`Targets/0/Schema/Tables/0/Columns/1/Format=json`,
+		`Targets/0/Schema/Tables/0/Columns/1/DbType=BLOB`,
+		`Targets/0/Schema/Tables/0/Columns/2/Compress=flate`,
+		`Targets/0/Schema/Tables/0/Columns/2/Format=gob`,
+		`Targets/1/Schema/Tables/0/Columns/1/Compress=gzip`,
+		`Targets/1/Schema/Tables/0/Columns/2/Compress=flate`,
+		`Targets/1/Schema/Tables/0/Columns/2/Format=gob`,
+	}
+	if err := jacl.Run(&have, want...); err != nil {
+		t.Fatalf("Has %v (%v)", have, err)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-MAKE-DRIVER-SIZED
+func TestMakeDriverSized(t *testing.T) {
+	settings := MakeDriverSettings{
+		Format:   "sqlite",
+		LoadGlob: "testdata/sized.go",
+		Pkg:      "sqlitegendriver",
+		Prefix:   "gen",
+	}
+	have, err := MakeDriverToMemory(settings)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	want := []string{`Tables/0/Columns/1/DbType=TEXT`,
+		`Tables/0/Columns/2/DbType="VARCHAR(4)"`,
+	}
+	if err := jacl.Run(&have.Schema, want...); err != nil {
+		t.Fatalf("Has schema %v (%v)", have.Schema, err)
+	}
+	// Bbolt has no column types.
+	settings.Format, settings.Pkg = "bbolt", "bboltgendriver"
+	_, err = MakeDriverToMemory(settings)
+	for _, w := range []string{`Sized.Notes: Tag keyword "type" is not supported by bbolt`,
+		`Sized.Code: Tag keyword "size" is not supported by bbolt`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), w) {
+			t.Fatalf("Has err %v but wants %v", err, w)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-MAKE-DRIVER-CONFIG
+func TestMakeDriverConfig(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	config := `{
+		"load": ["` + filepath.ToSlash(filepath.Join(wd, "domain", "filing.go")) + `", "` + filepath.ToSlash(filepath.Join(wd, "domain", "company.go")) + `"],
+		"targets": [{"format": "bbolt", "save": "gen", "pkg": "bboltgendriver", "prefix": "gen", "flags": ["lowercase"]}],
+		"structs": {
+			"Company": {"exclude": true},
+			"Filing": {"table": "filings", "fields": {"Form": "-", "Units": "name(u)"}}
+		}
+	}`
+	path := filepath.Join(t.TempDir(), "drivers.json")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	have, err := MakeDriverToMemory(MakeDriverSettings{ConfigPath: path})
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	want := []string{`Tables/0/Struct=Filing`,
+		`Tables/0/Name=filings`,
+		`Tables/0/Keys/0/Columns/0=ticker`,
+		`Tables/0/Keys/0/Columns/1=end`,
+		`Tables/0/Columns/3/Name=u`,
+		`Tables/{count}=1`,
+	}
+	if err := jacl.Run(&have.Schema, want...); err != nil {
+		t.Fatalf("Has schema %v (%v)", have.Schema, err)
+	}
+	// Output settings replace the values of the config targets.
+	have, err = MakeDriverToMemory(MakeDriverSettings{ConfigPath: path, Pkg: "otherdriver", Prefix: "oth"})
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	if src := string(have.Files["oth_const.go"]); !strings.HasPrefix(src, "package otherdriver") {
+		t.Fatalf("Has const file %v but wants package otherdriver", src)
+	}
+	// A save path can't replace the paths of several targets.
+	config = `{"targets": [{"format": "bbolt", "save": "a", "pkg": "a"}, {"format": "sqlite", "save": "b", "pkg": "b"}]}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	_, err = MakeDriverToMemory(MakeDriverSettings{ConfigPath: path, LoadGlob: "domain/filing.go", SavePath: "c"})
+	if err == nil || !strings.Contains(err.Error(), `save path "c" can't replace the paths of 2 config targets`) {
+		t.Fatalf("Has err %v but wants save path error", err)
+	}
+	// Every unknown override is reported, in name order.
+	config = `{"structs": {
+		"Zed": {"table": "z"},
+		"Filing": {"fields": {"Zulu": "-", "Alpha": "-", "Mike": "-"}}
+	}}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	_, err = MakeDriverToMemory(MakeDriverSettings{ConfigPath: path, LoadGlob: "domain/filing.go", Format: "bbolt", Pkg: "a"})
+	if err == nil {
+		t.Fatalf("Has no err")
+	}
+	want = []string{`Filing.Alpha`, `Filing.Mike`, `Filing.Zulu`, `unknown struct "Zed"`}
+	last := -1
+	for _, w := range want {
+		idx := strings.Index(err.Error(), w)
+		if idx <= last {
+			t.Fatalf("Has err %v but wants %v in order", err, want)
+		}
+		last = idx
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-MAKE-DRIVER-FILTERS
+func TestMakeDriverFilters(t *testing.T) {
+	settings := MakeDriverSettings{
+		LoadGlob:      "domain/*;domain2/*",
+		LoadSeparator: ";",
+		Exclude:       []string{"Company"},
+		Targets: []TargetSettings{
+			{Format: "sqlite", Pkg: "sqlitegendriver", Prefix: "gen", Exclude: []string{"Events"}},
+			{Format: "bbolt", Pkg: "bboltgendriver", Prefix: "gen", Include: []string{"Fil.*", "Events"}},
+		},
+	}
+	have, err := MakeDriverToMemory(settings)
+	if err != nil {
+		t.Fatalf("Has err %v", err)
+	}
+	want := []string{`Targets/0/Schema/Tables/{count}=6`,
+		`Targets/0/Schema/Tables/0/Struct=CollectionSetting`,
+		`Targets/0/Schema/Tables/1/Struct=Contact`,
+		`Targets/0/Schema/Tables/2/Struct=Document`,
+		`Targets/0/Schema/Tables/3/Struct=FavouritesSetting`,
+		`Targets/0/Schema/Tables/4/Struct=Filing`,
+		`Targets/0/Schema/Tables/5/Struct=UiSetting`,
+		`Targets/1/Schema/Tables/{count}=2`,
+		`Targets/1/Schema/Tables/0/Struct=Events`,
+		`Targets/1/Schema/Tables/1/Struct=Filing`,
+	}
+	if err := jacl.Run(&have, want...); err != nil {
+		t.Fatalf("Has %v (%v)", have, err)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-MAKE-DRIVER-ERRORS
+func TestMakeDriverErrors(t *testing.T) {
+	settings := MakeDriverSettings{
+		LoadGlob: "testdata/bad.go",
+		Targets: []TargetSettings{
+			{Format: "bbolt", Pkg: "bboltgendriver", Prefix: "gen"},
+		},
+	}
+	_, err := MakeDriverToMemory(settings)
+	if err == nil {
+		t.Fatalf("Has no err")
+	}
+	// Every error is reported, with its position.
+	want := []string{`bad.go:6: Bad.Count: Autoinc must be on uint64 type`,
+		`bad.go:7: Bad.Name: Unknown token "nmae"`,
+		`bad.go:11: NoKey: Metadata must have at least one key tag`,
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Fatalf("Has err %v but wants %v", err, w)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-LOCATE
+func TestLocate(t *testing.T) {
+	plain := errors.New("plain failure")
+	err := errors.Join(fmt.Errorf("sqlite: %w", enc.NewFieldError("Bad", "Name", errors.New("bad name"))), plain)
+	have := newSourceIndex("testdata/bad.go", "").locate(err)
+
+	// The field error is located and the plain error is kept.
+	if !errors.Is(have, plain) {
+		t.Fatalf("Has err %v but wants %v", have, plain)
+	}
+	var fe *enc.FieldError
+	if !errors.As(have, &fe) {
+		t.Fatalf("Has err %v but wants a field error", have)
+	}
+	want := filepath.FromSlash("testdata/bad.go") + ":7: Bad.Name: bad name"
+	if fe.Error() != want {
+		t.Fatalf("Has field error %v but wants %v", fe, want)
+	}
+}
+
+// ---------------------------------------------------------
+// TEST-LINT
+func TestLint(t *testing.T) {
+	f := func(glob string, want ...string) {
+		t.Helper()
+
+		have, err := Lint(MakeDriverSettings{LoadGlob: glob, LoadSeparator: ";"})
+		if err != nil {
+			t.Fatalf("Has err %v", err)
+		}
+		if len(have) != len(want) {
+			t.Fatalf("Has findings %v but wants %v", have, want)
+		}
+		for i, w := range want {
+			if !strings.Contains(have[i].String(), w) {
+				t.Fatalf("Has finding %v but wants %v", have[i], w)
+			}
+		}
+	}
+	f("domain/*;domain2/*")
+	f("testdata/bad.go",
+		`bad.go:6: error: bbolt: Bad.Count: Autoinc must be on uint64 type`,
+		`bad.go:6: error: sqlite: Bad.Count: Autoinc must be the only column in the primary key`,
+		`bad.go:7: error: bbolt: Bad.Name: Unknown token "nmae"`,
+		`bad.go:7: error: sqlite: Bad.Name: Unknown token "nmae"`,
+		`bad.go:11: error: bbolt: NoKey: Metadata must have at least one key tag`,
+	)
+	f("testdata/dup.go",
+		`dup.go:7: error: bbolt: Dup.B: Column "x" is already used by A`,
+		`dup.go:7: error: sqlite: Dup.B: Column "x" is already used by A`,
+	)
+	f("testdata/shared.go",
+		`shared.go:13: error: sqlite: SharedToo.Value: Column "value" is VARCHAR(255) but INTEGER in Shared`,
+		`shared.go:14: warning: bbolt: SharedToo.Extra: Column "Extra" is in table "shared" but not in Shared`,
+		`shared.go:14: warning: sqlite: SharedToo.Extra: Column "extra" is in table "shared" but not in Shared`,
+	)
+}
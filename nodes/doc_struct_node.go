@@ -0,0 +1,239 @@
+package nodes
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/hackborn/doc_drivers/enc"
+	"github.com/hackborn/onefunc/pipeline"
+)
+
+// docStructNode converts the domain source to StructData, the
+// same as the struct node, except that nested structs are
+// flattened. The fields of embedded structs, and of struct
+// fields tagged inline or flatten(prefix), are expanded in
+// place. Expanded fields are named by their path, i.e.
+// "Address.City", and carry the flatten prefix in their tag.
+type docStructNode struct {
+	docStructData
+}
+
+type docStructData struct {
+	// Tag is the name of the tag to extract from each field,
+	// i.e. "doc". If empty, the whole tag is used.
+	Tag string
+}
+
+// docStruct is a parsed struct before flattening.
+type docStruct struct {
+	data *pipeline.StructData
+	// embedded are the names of the embedded fields.
+	embedded map[string]bool
+}
+
+// flatField is an expanded field and the prefix
+// added to its name by the structs it's flattened from.
+type flatField struct {
+	field  pipeline.StructField
+	prefix string
+}
+
+func (n *docStructNode) Start(input pipeline.StartInput) error {
+	data := n.docStructData
+	input.SetNodeData(&data)
+	return nil
+}
+
+func (n *docStructNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*docStructData)
+	// Flattened structs can be in any file, so every
+	// struct is parsed before any are flattened.
+	var structs []*docStruct
+	for _, pin := range input.Pins {
+		if p, ok := pin.Payload.(*pipeline.ContentData); ok {
+			parsed, err := data.parse(p)
+			if err != nil {
+				return err
+			}
+			structs = append(structs, parsed...)
+		}
+	}
+	f := newFlattener(structs)
+	eb := &enc.ListBlock{}
+	for _, ds := range structs {
+		fields, err := f.expand(ds)
+		eb.AddError(err)
+		sd := *ds.data
+		sd.Fields = make([]pipeline.StructField, 0, len(fields))
+		for _, ff := range fields {
+			sd.Fields = append(sd.Fields, ff.render())
+		}
+		output.Pins = append(output.Pins, pipeline.Pin{Payload: &sd})
+	}
+	return eb.Err()
+}
+
+func (d *docStructData) parse(pin *pipeline.ContentData) ([]*docStruct, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", pin.Data, 0)
+	if err != nil {
+		return nil, err
+	}
+	var structs []*docStruct
+	ast.Inspect(f, func(node ast.Node) bool {
+		if spec, ok := node.(*ast.TypeSpec); ok {
+			if st, ok := spec.Type.(*ast.StructType); ok {
+				structs = append(structs, d.newDocStruct(spec, st))
+			}
+		}
+		return true
+	})
+	return structs, nil
+}
+
+func (d *docStructData) newDocStruct(spec *ast.TypeSpec, st *ast.StructType) *docStruct {
+	ds := &docStruct{data: &pipeline.StructData{Name: spec.Name.Name}, embedded: make(map[string]bool)}
+	for _, field := range st.Fields.List {
+		typeName := pipeline.UnknownType
+		if ident, ok := field.Type.(*ast.Ident); ok {
+			typeName = ident.Name
+		}
+		sf := pipeline.StructField{Type: typeName,
+			RawType: types.ExprString(field.Type),
+			Tag:     d.fieldTag(field),
+		}
+		switch {
+		case len(field.Names) > 0:
+			sf.Name = field.Names[0].Name
+		case typeName != pipeline.UnknownType && ast.IsExported(typeName):
+			// Embedded structs are named after their type. Pointers
+			// and types from other packages can't be flattened.
+			sf.Name = typeName
+			ds.embedded[sf.Name] = true
+		default:
+			continue
+		}
+		if ast.IsExported(sf.Name) {
+			ds.data.Fields = append(ds.data.Fields, sf)
+		} else {
+			ds.data.UnexportedFields = append(ds.data.UnexportedFields, sf)
+		}
+	}
+	return ds
+}
+
+func (d *docStructData) fieldTag(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	tag := strings.Trim(field.Tag.Value, "`")
+	if d.Tag == "" {
+		return tag
+	}
+	return reflect.StructTag(tag).Get(d.Tag)
+}
+
+// flattener expands the structs, remembering each
+// result so every struct is only reported once.
+type flattener struct {
+	structs  map[string]*docStruct
+	expanded map[string][]flatField
+	errs     map[string]error
+	visiting []string
+}
+
+func newFlattener(structs []*docStruct) *flattener {
+	f := &flattener{structs: make(map[string]*docStruct), expanded: make(map[string][]flatField), errs: make(map[string]error)}
+	for _, ds := range structs {
+		// The first struct with a name wins.
+		if _, ok := f.structs[ds.data.Name]; !ok {
+			f.structs[ds.data.Name] = ds
+		}
+	}
+	return f
+}
+
+// expand answers the fields of the struct with
+// every flattened struct expanded.
+func (f *flattener) expand(ds *docStruct) ([]flatField, error) {
+	name := ds.data.Name
+	if fields, ok := f.expanded[name]; ok {
+		return fields, f.errs[name]
+	}
+	f.visiting = append(f.visiting, name)
+	defer func() {
+		f.visiting = f.visiting[:len(f.visiting)-1]
+	}()
+	eb := &enc.ListBlock{}
+	var fields []flatField
+	for _, field := range ds.data.Fields {
+		pt, err := enc.ParseTag(field.Tag)
+		embedded := ds.embedded[field.Name]
+		if !embedded && (err != nil || (!pt.Inline && pt.Flatten == "")) {
+			// Backends report any tag errors.
+			fields = append(fields, flatField{field: field})
+			continue
+		}
+		if pt.Name == "-" {
+			continue
+		}
+		// Untagged embedded types that aren't domain structs
+		// are left out, the same as the struct node.
+		if _, ok := f.structs[field.RawType]; embedded && field.Tag == "" && !ok {
+			continue
+		}
+		inner, err := f.expandField(field, pt, err)
+		eb.AddError(enc.NewFieldError(name, field.Name, err))
+		for _, ff := range inner {
+			ff.field.Name = field.Name + "." + ff.field.Name
+			ff.prefix = pt.Flatten + ff.prefix
+			fields = append(fields, ff)
+		}
+	}
+	f.expanded[name], f.errs[name] = fields, eb.Err()
+	return fields, eb.Err()
+}
+
+// expandField answers the fields of the struct type of field.
+func (f *flattener) expandField(field pipeline.StructField, pt enc.Tag, err error) ([]flatField, error) {
+	if err != nil {
+		return nil, err
+	}
+	for _, kw := range pt.Keywords {
+		if kw != "inline" && kw != "flatten" {
+			return nil, fmt.Errorf("Flattened structs can only be tagged inline or flatten")
+		}
+	}
+	ds, ok := f.structs[field.RawType]
+	if !ok {
+		return nil, fmt.Errorf("Flatten requires a struct type in the domain, has %v", field.RawType)
+	}
+	if slices.Contains(f.visiting, ds.data.Name) {
+		return nil, fmt.Errorf("Struct %v can't be flattened into itself", ds.data.Name)
+	}
+	// Errors inside the flattened struct are reported with it.
+	fields, _ := f.expand(ds)
+	return fields, nil
+}
+
+// render answers the field with the prefix added to its tag.
+// The first flatten in a tag wins, so the prefix is added
+// to the front.
+func (ff flatField) render() pipeline.StructField {
+	if ff.prefix == "" {
+		return ff.field
+	}
+	sf := ff.field
+	tag := "flatten(" + ff.prefix + ")"
+	if sf.Tag != "" {
+		tag += ", " + sf.Tag
+	}
+	sf.Tag = tag
+	return sf
+}
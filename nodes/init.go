@@ -10,6 +10,13 @@ func init() {
 	// Register the test data
 	pipeline.RegisterFs("testnodedata", testNodeDataFs)
 
+	pipeline.RegisterNode("docstruct", func() pipeline.Node {
+		return &docStructNode{}
+	})
+	pipeline.RegisterNode("savedriver", newSaveDriverNode)
+	pipeline.RegisterNode("structfilter", func() pipeline.Node {
+		return &structFilterNode{}
+	})
 	pipeline.RegisterNode("testgen", func() pipeline.Node {
 		return newTestDocDriverNode("gen")
 	})
@@ -0,0 +1,189 @@
+package nodes
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hackborn/doc_drivers/registry"
+	"github.com/hackborn/onefunc/pipeline"
+)
+
+const (
+	// SaveModeSave writes every ContentData to the save path.
+	SaveModeSave = "save"
+	// SaveModeCheck compares every ContentData to the file in the
+	// save path without writing anything, reporting differences
+	// as a StaleError.
+	SaveModeCheck = "check"
+	// SaveModeMemory writes nothing, leaving the ContentData
+	// in the pipeline output.
+	SaveModeMemory = "memory"
+)
+
+func newSaveDriverNode() pipeline.Node {
+	n := &saveDriverNode{}
+	n.Mode = SaveModeSave
+	return n
+}
+
+// saveDriverNode is the sink for generated drivers. It either
+// saves the content to files or diffs it against the existing files.
+type saveDriverNode struct {
+	saveDriverData
+}
+
+type saveDriverData struct {
+	// Path is the folder the content is saved to.
+	Path string
+
+	// Mode is one of the SaveMode constants.
+	Mode string
+
+	// Building -- the results of a check.
+	stale    StaleError
+	produced map[string]struct{}
+}
+
+func (n *saveDriverNode) Start(input pipeline.StartInput) error {
+	data := n.saveDriverData
+	data.produced = make(map[string]struct{})
+	input.SetNodeData(&data)
+	return nil
+}
+
+func (n *saveDriverNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*saveDriverData)
+	path := filepath.FromSlash(data.Path)
+	if data.Mode == SaveModeSave {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return fmt.Errorf("save driver: %w", err)
+		}
+	}
+	for _, pin := range input.Pins {
+		switch p := pin.Payload.(type) {
+		case *pipeline.ContentData:
+			if err := n.runContentPin(data, p, path); err != nil {
+				return err
+			}
+		}
+		output.Pins = append(output.Pins, pin)
+	}
+	return nil
+}
+
+func (n *saveDriverNode) runContentPin(data *saveDriverData, pin *pipeline.ContentData, path string) error {
+	if pin.Name == "" {
+		return fmt.Errorf("save driver: pin supplied with no name")
+	}
+	fn := filepath.Join(path, pin.Name)
+	switch data.Mode {
+	case SaveModeSave:
+		return os.WriteFile(fn, []byte(pin.Data), 0644)
+	case SaveModeCheck:
+		data.produced[pin.Name] = struct{}{}
+		existing, err := os.ReadFile(fn)
+		if os.IsNotExist(err) {
+			data.stale.Added = append(data.stale.Added, pin.Name)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !bytes.Equal(existing, []byte(pin.Data)) {
+			data.stale.Changed = append(data.stale.Changed, pin.Name)
+		}
+		return nil
+	case SaveModeMemory:
+		return nil
+	default:
+		return fmt.Errorf("save driver: unknown mode \"%v\"", data.Mode)
+	}
+}
+
+func (n *saveDriverNode) Flush(state *pipeline.State, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*saveDriverData)
+	if data.Mode != SaveModeCheck {
+		return nil
+	}
+	deleted, err := n.findDeleted(data)
+	if err != nil {
+		return err
+	}
+	data.stale.Deleted = deleted
+	if data.stale.Empty() {
+		return nil
+	}
+	slices.Sort(data.stale.Added)
+	slices.Sort(data.stale.Changed)
+	stale := data.stale
+	stale.Path = data.Path
+	return &stale
+}
+
+// findDeleted answers the generated files in the save path
+// that would no longer be produced.
+func (n *saveDriverNode) findDeleted(data *saveDriverData) ([]string, error) {
+	entries, err := os.ReadDir(filepath.FromSlash(data.Path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if _, ok := data.produced[e.Name()]; ok {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(filepath.FromSlash(data.Path), e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if isAutogenerated(content) {
+			deleted = append(deleted, e.Name())
+		}
+	}
+	return deleted, nil
+}
+
+// isAutogenerated answers true if the content has the
+// generator marker in its header.
+func isAutogenerated(content []byte) bool {
+	const headerSize = 512
+	if len(content) > headerSize {
+		content = content[:headerSize]
+	}
+	return bytes.Contains(content, []byte(registry.AutogeneratedPrefix))
+}
+
+// StaleError reports the files that differ between a
+// generated driver and the driver already on disk.
+type StaleError struct {
+	Path    string
+	Added   []string
+	Changed []string
+	Deleted []string
+}
+
+func (e *StaleError) Empty() bool {
+	return len(e.Added) == 0 && len(e.Changed) == 0 && len(e.Deleted) == 0
+}
+
+func (e *StaleError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("driver is stale in \"" + e.Path + "\"")
+	write := func(label string, names []string) {
+		for _, name := range names {
+			sb.WriteString("\n\t" + label + " " + name)
+		}
+	}
+	write("added", e.Added)
+	write("changed", e.Changed)
+	write("deleted", e.Deleted)
+	return sb.String()
+}
@@ -0,0 +1,70 @@
+package nodes
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hackborn/onefunc/pipeline"
+)
+
+// structFilterNode keeps structs out of the driver by name. Filtered
+// structs are marked as skipped, the same as a `doc:"-"` table tag,
+// instead of being removed, because they might still be used as
+// field types in structs that are kept.
+type structFilterNode struct {
+	structFilterData
+}
+
+type structFilterData struct {
+	// Include is a regular expression. If set, only struct
+	// names that match are kept.
+	Include string
+
+	// Exclude is a regular expression. If set, struct names
+	// that match are skipped.
+	Exclude string
+}
+
+func (n *structFilterNode) Start(input pipeline.StartInput) error {
+	data := n.structFilterData
+	input.SetNodeData(&data)
+	return nil
+}
+
+func (n *structFilterNode) Run(state *pipeline.State, input pipeline.RunInput, output *pipeline.RunOutput) error {
+	data := state.NodeData.(*structFilterData)
+	include, err := compileFilter(data.Include)
+	if err != nil {
+		return fmt.Errorf("struct filter include: %w", err)
+	}
+	exclude, err := compileFilter(data.Exclude)
+	if err != nil {
+		return fmt.Errorf("struct filter exclude: %w", err)
+	}
+	for _, pin := range input.Pins {
+		if sd, ok := pin.Payload.(*pipeline.StructData); ok {
+			if (include != nil && !include.MatchString(sd.Name)) || (exclude != nil && exclude.MatchString(sd.Name)) {
+				pin.Payload = skipStruct(sd)
+			}
+		}
+		output.Pins = append(output.Pins, pin)
+	}
+	return nil
+}
+
+func compileFilter(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// skipStruct answers a copy of the struct with a skip table tag.
+// Table tags are read in order, so this replaces any existing name.
+func skipStruct(src *pipeline.StructData) *pipeline.StructData {
+	sd := *src
+	sd.UnexportedFields = make([]pipeline.StructField, 0, len(src.UnexportedFields)+1)
+	sd.UnexportedFields = append(sd.UnexportedFields, src.UnexportedFields...)
+	sd.UnexportedFields = append(sd.UnexportedFields, pipeline.StructField{Name: "_table", Tag: "-"})
+	return &sd
+}
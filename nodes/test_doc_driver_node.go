@@ -3,8 +3,11 @@ package nodes
 import (
 	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hackborn/onefunc/jacl"
 	"github.com/hackborn/onefunc/pipeline"
@@ -12,6 +15,7 @@ import (
 	"github.com/hackborn/doc"
 	"github.com/hackborn/doc_drivers/domain"
 	"github.com/hackborn/doc_drivers/domain2"
+	"github.com/hackborn/doc_drivers/driverkit"
 	"github.com/hackborn/doc_drivers/registry"
 )
 
@@ -77,11 +81,12 @@ func (n *testDocDriverNode) runContent(data *testDocDriverData, cd *pipeline.Con
 	defer db.Close()
 
 	// Run tests
+	vars := make(testVars)
 	for i, te := range entries {
 		if !n.filterFn(cd.Name, i) {
 			continue
 		}
-		err = cmp.Or(err, n.errWrap(n.runTest(db, te), cd.Name, i))
+		err = cmp.Or(err, n.errWrap(n.runTest(db, te, vars), cd.Name, i))
 	}
 	return err
 }
@@ -93,10 +98,10 @@ func (n *testDocDriverNode) errWrap(err error, filename string, index int) error
 	return err
 }
 
-func (n *testDocDriverNode) runTest(db *doc.DB, te testEntry) error {
+func (n *testDocDriverNode) runTest(db *doc.DB, te testEntry, vars testVars) error {
 	switch te.Command {
 	case "get":
-		return n.runGetTest(db, te)
+		return n.runGetTest(db, te, vars)
 	case "set":
 		return n.runSetTest(db, te)
 	case "delete":
@@ -106,18 +111,24 @@ func (n *testDocDriverNode) runTest(db *doc.DB, te testEntry) error {
 	}
 }
 
-func (n *testDocDriverNode) runGetTest(db *doc.DB, te testEntry) error {
+func (n *testDocDriverNode) runGetTest(db *doc.DB, te testEntry, vars testVars) error {
 	switch te.Type {
 	case "CollectionSetting":
-		return runGetTest[domain.CollectionSetting](db, te)
+		return runGetTest[domain.CollectionSetting](db, te, vars)
+	case "Contact":
+		return runGetTest[domain.Contact](db, te, vars)
+	case "Company":
+		return runGetTest[domain.Company](db, te, vars)
+	case "Document":
+		return runGetTest[domain.Document](db, te, vars)
 	case "Events":
-		return runGetTest[domain.Events](db, te)
+		return runGetTest[domain.Events](db, te, vars)
 	case "FavouritesSetting":
-		return runGetTest[domain.FavouritesSetting](db, te)
+		return runGetTest[domain.FavouritesSetting](db, te, vars)
 	case "Filing":
-		return runGetTest[domain.Filing](db, te)
+		return runGetTest[domain.Filing](db, te, vars)
 	case "UiSetting":
-		return runGetTest[domain2.UiSetting](db, te)
+		return runGetTest[domain2.UiSetting](db, te, vars)
 	default:
 		return fmt.Errorf("Unhandled type \"%v\" for get", te.Type)
 	}
@@ -127,6 +138,12 @@ func (n *testDocDriverNode) runSetTest(db *doc.DB, te testEntry) error {
 	switch te.Type {
 	case "CollectionSetting":
 		return runSetTest[domain.CollectionSetting](db, te)
+	case "Contact":
+		return runSetTest[domain.Contact](db, te)
+	case "Company":
+		return runSetTest[domain.Company](db, te)
+	case "Document":
+		return runSetTest[domain.Document](db, te)
 	case "Events":
 		return runSetTest[domain.Events](db, te)
 	case "FavouritesSetting":
@@ -144,6 +161,12 @@ func (n *testDocDriverNode) runDeleteTest(db *doc.DB, te testEntry) error {
 	switch te.Type {
 	case "CollectionSetting":
 		return runDeleteTest[domain.CollectionSetting](db, te)
+	case "Contact":
+		return runDeleteTest[domain.Contact](db, te)
+	case "Company":
+		return runDeleteTest[domain.Company](db, te)
+	case "Document":
+		return runDeleteTest[domain.Document](db, te)
 	case "Events":
 		return runDeleteTest[domain.Events](db, te)
 	case "FavouritesSetting":
@@ -157,7 +180,7 @@ func (n *testDocDriverNode) runDeleteTest(db *doc.DB, te testEntry) error {
 	}
 }
 
-func runGetTest[T any](db *doc.DB, te testEntry) error {
+func runGetTest[T any](db *doc.DB, te testEntry, vars testVars) error {
 	req := doc.GetRequest{}
 	var err error
 	req.Condition, err = db.Expr(te.Expr, nil).Compile()
@@ -174,7 +197,7 @@ func runGetTest[T any](db *doc.DB, te testEntry) error {
 			fmt.Println("\t", item)
 		}
 	*/
-	return jacl.Run(resp.Results, te.Response...)
+	return cmp.Or(jacl.Run(resp.Results, te.Response...), te.checkValues(resp.Results, vars))
 }
 
 func runSetTest[T any](db *doc.DB, te testEntry) error {
@@ -184,6 +207,9 @@ func runSetTest[T any](db *doc.DB, te testEntry) error {
 	}
 	req := doc.SetRequest[T]{Item: fitem, Filter: te.MakeFilter()}
 	resp, err := doc.Set(db, req)
+	if te.Error != "" || err != nil {
+		return te.checkErr(err)
+	}
 	// The API is currently unclear on whether a return item
 	// is required, but I think all the drivers ignore it right
 	// now so we'll just assume it's optional.
@@ -221,6 +247,152 @@ type testEntry struct {
 	Item     map[string]any `json:"item"`
 	Filter   string         `json:"filter"`
 	Response []string       `json:"response"`
+	// Error is text the error must contain. If set,
+	// the command must fail.
+	Error string `json:"error"`
+	// Is names the driverkit error the error must match,
+	// and ErrResponse is checked against that error as
+	// found by errors.As, the same as Response.
+	Is          string   `json:"is"`
+	ErrResponse []string `json:"errResponse"`
+	// Values the driver sets can't be known by the test,
+	// so they're checked by path into the JSON form of the
+	// response. Recent are timestamps that must be from the
+	// last minute. Save stores the value at each path under
+	// its name, and Same requires the value at each path to
+	// equal the saved value of that name.
+	Recent []string          `json:"recent"`
+	Save   map[string]string `json:"save"`
+	Same   map[string]string `json:"same"`
+}
+
+// testVars are the values saved by the tests in a file.
+type testVars map[string]any
+
+func (e testEntry) checkValues(resp any, vars testVars) error {
+	if len(e.Recent) < 1 && len(e.Save) < 1 && len(e.Same) < 1 {
+		return nil
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	var v any
+	err = json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	for _, path := range e.Recent {
+		have, err := testValueAt(v, path)
+		if err == nil {
+			err = testCheckRecent(have)
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+	}
+	for name, path := range e.Save {
+		have, err := testValueAt(v, path)
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		vars[name] = have
+	}
+	for name, path := range e.Same {
+		have, err := testValueAt(v, path)
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		want, ok := vars[name]
+		if !ok {
+			return fmt.Errorf("No saved value \"%v\"", name)
+		}
+		if have != want {
+			return fmt.Errorf("%v: Have value \"%v\" but want \"%v\"", path, have, want)
+		}
+	}
+	return nil
+}
+
+// testValueAt answers the value at the slash-separated
+// path into the unmarshalled JSON.
+func testValueAt(v any, path string) (any, error) {
+	for _, name := range strings.Split(path, "/") {
+		switch t := v.(type) {
+		case []any:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, fmt.Errorf("Can't navigate to \"%v\"", name)
+			}
+			v = t[i]
+		case map[string]any:
+			var ok bool
+			if v, ok = t[name]; !ok {
+				return nil, fmt.Errorf("Can't navigate to \"%v\"", name)
+			}
+		default:
+			return nil, fmt.Errorf("Can't navigate to \"%v\"", name)
+		}
+	}
+	return v, nil
+}
+
+// testCheckRecent answers an error if the timestamp, either a
+// time or milliseconds since the epoch, isn't from the last minute.
+func testCheckRecent(v any) error {
+	var t time.Time
+	switch s := v.(type) {
+	case float64:
+		t = time.UnixMilli(int64(s))
+	case string:
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Value \"%v\" isn't a timestamp", v)
+	}
+	if since := time.Since(t); since < 0 || since > time.Minute {
+		return fmt.Errorf("Timestamp %v isn't recent", t)
+	}
+	return nil
+}
+
+func (e testEntry) checkErr(err error) error {
+	switch {
+	case e.Error == "":
+		return err
+	case err == nil:
+		return fmt.Errorf("Want error \"%v\" but has none", e.Error)
+	case !strings.Contains(err.Error(), e.Error):
+		return fmt.Errorf("Want error \"%v\" but has \"%v\"", e.Error, err)
+	case e.Is == "":
+		return nil
+	}
+	target, ok := testErrTargets[e.Is]
+	if !ok {
+		return fmt.Errorf("No error target \"%v\"", e.Is)
+	}
+	if !errors.Is(err, target.sentinel) {
+		return fmt.Errorf("Want errors.Is(%v) but has \"%v\"", e.Is, err)
+	}
+	as := target.as()
+	if !errors.As(err, as) {
+		return fmt.Errorf("Want errors.As(%T) but has \"%v\"", as, err)
+	}
+	return jacl.Run(as, e.ErrResponse...)
+}
+
+// testErrTarget is an error that can be matched by name.
+// As answers a new pointer for errors.As.
+type testErrTarget struct {
+	sentinel error
+	as       func() any
+}
+
+var testErrTargets = map[string]testErrTarget{
+	"unique":   {sentinel: driverkit.ErrUnique, as: func() any { return new(*driverkit.UniqueError) }},
+	"conflict": {sentinel: driverkit.ErrConflict, as: func() any { return new(*driverkit.ConflictError) }},
 }
 
 func (e testEntry) MakeFilter() doc.Filter {
@@ -0,0 +1,122 @@
+package drivers
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+
+	"github.com/hackborn/doc_drivers/enc"
+)
+
+// sourceIndex locates the domain structs and their
+// fields in the source files, by struct name.
+type sourceIndex map[string]sourceStruct
+
+type sourceStruct struct {
+	pos    token.Position
+	fields map[string]token.Position
+}
+
+// newSourceIndex parses the files matched by the globs. Positions are
+// only used to annotate errors, so files that can't be read or parsed
+// are skipped; the load itself will report them.
+func newSourceIndex(glob, separator string) sourceIndex {
+	idx := make(sourceIndex)
+	globs := []string{glob}
+	if separator != "" {
+		globs = strings.Split(glob, separator)
+	}
+	fset := token.NewFileSet()
+	for _, g := range globs {
+		matches, _ := filepath.Glob(filepath.FromSlash(g))
+		for _, fn := range matches {
+			f, err := parser.ParseFile(fset, fn, nil, parser.SkipObjectResolution)
+			if err != nil {
+				continue
+			}
+			idx.addFile(fset, f)
+		}
+	}
+	return idx
+}
+
+func (idx sourceIndex) addFile(fset *token.FileSet, f *ast.File) {
+	ast.Inspect(f, func(node ast.Node) bool {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return false
+		}
+		// The first struct with a name wins.
+		if _, ok := idx[spec.Name.Name]; ok {
+			return false
+		}
+		ss := sourceStruct{pos: fset.Position(spec.Pos()), fields: make(map[string]token.Position)}
+		for _, field := range st.Fields.List {
+			for _, name := range field.Names {
+				ss.fields[name.Name] = fset.Position(name.Pos())
+			}
+		}
+		idx[spec.Name.Name] = ss
+		return false
+	})
+}
+
+// locate answers the error tree with the source position filled
+// in on every enc.FieldError. Field errors and the errors beside
+// them are answered as a flat list; a wrapping error is dropped
+// for the field errors it holds, since its message is already
+// rendered into theirs. If there are no field errors, err is
+// answered unchanged.
+func (idx sourceIndex) locate(err error) error {
+	var fe *enc.FieldError
+	if !errors.As(err, &fe) {
+		return err
+	}
+	var found []error
+	var walk func(error)
+	walk = func(err error) {
+		var fe *enc.FieldError
+		if !errors.As(err, &fe) {
+			found = append(found, err)
+			return
+		}
+		switch e := err.(type) {
+		case *enc.FieldError:
+			if e.File == "" {
+				e.File, e.Line = idx.position(e.Struct, e.Field)
+			}
+			found = append(found, e)
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				walk(inner)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		default:
+			found = append(found, err)
+		}
+	}
+	walk(err)
+	return errors.Join(found...)
+}
+
+// position answers the file and line of the field, or of
+// the struct if the field is empty or unknown.
+func (idx sourceIndex) position(structName, fieldName string) (string, int) {
+	ss, ok := idx[structName]
+	if !ok {
+		return "", 0
+	}
+	pos := ss.pos
+	if p, ok := ss.fields[fieldName]; ok {
+		pos = p
+	}
+	return pos.Filename, pos.Line
+}
@@ -28,8 +28,10 @@ type Factory struct {
 
 func NewFactory(graphEntries map[string]graphs.Entry) Factory {
 	graphNames := []string{}
-	for k, _ := range graphEntries {
-		graphNames = append(graphNames, k)
+	for k, v := range graphEntries {
+		if !v.Hidden {
+			graphNames = append(graphNames, k)
+		}
 	}
 	slices.Sort(graphNames)
 
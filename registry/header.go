@@ -0,0 +1,61 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+
+	"github.com/hackborn/onefunc/pipeline"
+)
+
+const (
+	// AutogeneratedPrefix starts the header line of every generated file.
+	AutogeneratedPrefix = "// autogenerated with "
+
+	// InputHashPrefix starts the optional header line with the input hash.
+	InputHashPrefix = "// input hash "
+)
+
+// InputHash answers a hash of the generator inputs: The settings
+// that affect the output, and the source structs. The result is
+// independent of the order the structs were loaded in.
+func InputHash(settings []string, structs []*pipeline.StructData) string {
+	structs = slices.Clone(structs)
+	slices.SortFunc(structs, func(a, b *pipeline.StructData) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	h := sha256.New()
+	write := func(s ...string) {
+		for _, v := range s {
+			h.Write([]byte(v))
+			h.Write([]byte{0})
+		}
+	}
+	write(settings...)
+	for _, sd := range structs {
+		write("struct", sd.Name)
+		for _, f := range sd.Fields {
+			write(f.Name, f.Type, f.RawType, f.Tag)
+		}
+		for _, f := range sd.UnexportedFields {
+			write(f.Name, f.Type, f.RawType, f.Tag)
+		}
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// AddInputHash inserts the input hash into the header of the
+// generated content, after the autogenerated line.
+func AddInputHash(content, hash string) string {
+	start := strings.Index(content, AutogeneratedPrefix)
+	if start < 0 {
+		return content
+	}
+	end := strings.IndexByte(content[start:], '\n')
+	if end < 0 {
+		return content + "\n" + InputHashPrefix + hash
+	}
+	end += start + 1
+	return content[:end] + InputHashPrefix + hash + "\n" + content[end:]
+}
@@ -14,6 +14,7 @@ func Register(f Factory) error {
 
 // Open a backend. The backend will register any
 // required dependencies (nodes, filesystems, etc.)
+// the first time it is opened.
 func Open(name string) (Factory, error) {
 	return reg.Open(name)
 }
@@ -30,11 +31,13 @@ func Names() []string {
 type registry struct {
 	lock      sync.Mutex
 	factories map[string]Factory
+	opened    map[string]bool
 }
 
 func newRegistry() *registry {
 	factories := make(map[string]Factory)
-	return &registry{factories: factories}
+	opened := make(map[string]bool)
+	return &registry{factories: factories, opened: opened}
 }
 
 func (r *registry) Register(f Factory) error {
@@ -49,11 +52,12 @@ func (r *registry) Register(f Factory) error {
 func (r *registry) Open(name string) (Factory, error) {
 	defer lock.Locker(&r.lock).Unlock()
 	if f, ok := r.factories[name]; ok {
-		if f.Open != nil {
+		if f.Open != nil && !r.opened[name] {
 			err := f.Open()
 			if err != nil {
 				return Factory{}, err
 			}
+			r.opened[name] = true
 		}
 		return f, nil
 	}
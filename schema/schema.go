@@ -0,0 +1,145 @@
+package schema
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/hackborn/onefunc/pipeline"
+)
+
+// Schema describes the tables a driver was generated from.
+// It is produced by the backend go nodes as a pipeline payload.
+type Schema struct {
+	// Format is the storage format of the driver.
+	Format string `json:"format"`
+
+	Tables []Table `json:"tables"`
+}
+
+func (s *Schema) Clone() pipeline.Cloner {
+	dst := &Schema{Format: s.Format, Tables: make([]Table, 0, len(s.Tables))}
+	for _, t := range s.Tables {
+		dst.Tables = append(dst.Tables, t.clone())
+	}
+	return dst
+}
+
+// Manifest answers the schema as a JSON file, so tools
+// outside the pipeline can work from it.
+func (s *Schema) Manifest() (*pipeline.ContentData, error) {
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, '\n')
+	return &pipeline.ContentData{Name: ManifestName, Data: string(b)}, nil
+}
+
+// Table describes the storage for a single domain struct.
+type Table struct {
+	// Struct is the name of the domain struct.
+	Struct string `json:"struct"`
+
+	// Name is the name of the table in the database.
+	Name string `json:"name"`
+
+	Columns []Column `json:"columns"`
+
+	// Keys are the key groups for the table, ordered
+	// so the primary key is first.
+	Keys []KeyGroup `json:"keys"`
+
+	// Indexes are the secondary indexes, ordered by name.
+	Indexes []Index `json:"indexes,omitempty"`
+
+	// Uniques are the unique constraints, ordered by name.
+	Uniques []KeyGroup `json:"uniques,omitempty"`
+
+	// Compress is the algorithm that compresses each
+	// stored record, if the backend compresses records.
+	Compress string `json:"compress,omitempty"`
+}
+
+func (t Table) clone() Table {
+	dst := t
+	dst.Columns = make([]Column, 0, len(t.Columns))
+	for _, c := range t.Columns {
+		c.Flags = slices.Clone(c.Flags)
+		c.Extra = maps.Clone(c.Extra)
+		c.Was = slices.Clone(c.Was)
+		dst.Columns = append(dst.Columns, c)
+	}
+	dst.Keys = make([]KeyGroup, 0, len(t.Keys))
+	for _, k := range t.Keys {
+		dst.Keys = append(dst.Keys, KeyGroup{Name: k.Name, Columns: slices.Clone(k.Columns)})
+	}
+	dst.Indexes = slices.Clone(t.Indexes)
+	for i, idx := range dst.Indexes {
+		dst.Indexes[i].Columns = slices.Clone(idx.Columns)
+	}
+	dst.Uniques = slices.Clone(t.Uniques)
+	for i, u := range dst.Uniques {
+		dst.Uniques[i].Columns = slices.Clone(u.Columns)
+	}
+	return dst
+}
+
+// Column describes a single stored field.
+type Column struct {
+	// Field is the name of the field in the domain struct.
+	Field string `json:"field"`
+
+	// Name is the name of the column in the database.
+	Name string `json:"name"`
+
+	// Type is the Go type of the field.
+	Type string `json:"type"`
+
+	// DbType is the type of the column in the database.
+	DbType string `json:"dbType"`
+
+	// Format is the format the value is translated
+	// to when stored, if any.
+	Format string `json:"format,omitempty"`
+
+	// Flags are the column flags, in their tag form.
+	Flags []string `json:"flags,omitempty"`
+
+	// Default is the value for records that have none stored.
+	Default string `json:"default,omitempty"`
+
+	// Extra are the values set by registered tag keywords.
+	Extra map[string]any `json:"extra,omitempty"`
+
+	// Was are the names the column had before it was renamed.
+	Was []string `json:"was,omitempty"`
+
+	// Compress is the algorithm that compresses the stored
+	// value, if any.
+	Compress string `json:"compress,omitempty"`
+}
+
+// KeyGroup describes a single, possibly compound, key.
+type KeyGroup struct {
+	Name string `json:"name"`
+
+	// Columns are the database names of the key columns, in order.
+	Columns []string `json:"columns"`
+}
+
+// Index describes a secondary index. Indexes are used for
+// queries; unlike keys, they aren't part of the identity.
+type Index struct {
+	Name string `json:"name"`
+
+	// Columns are the database names of the index columns, in order.
+	Columns []string `json:"columns"`
+
+	Unique bool `json:"unique,omitempty"`
+}
+
+const (
+	// ManifestName is the file name of the schema manifest.
+	ManifestName = "schema.json"
+)
@@ -0,0 +1,13 @@
+package bad
+
+// Bad has errors in its tags.
+type Bad struct {
+	Id    string `doc:"key"`
+	Count int    `doc:"key, autoinc"`
+	Name  string `doc:"nmae(x)"`
+}
+
+// NoKey has no key, which not every backend allows.
+type NoKey struct {
+	Name string
+}
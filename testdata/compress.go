@@ -0,0 +1,10 @@
+package bad
+
+// Archive compresses each stored record, which
+// only bbolt can do.
+type Archive struct {
+	Id   string `doc:"key"`
+	Body string `doc:"compress(flate)"`
+
+	_table int `doc:"compress(gzip)"`
+}
@@ -0,0 +1,8 @@
+package bad
+
+// Dup stores two fields in one column.
+type Dup struct {
+	Id string `doc:"key"`
+	A  string `doc:"name(x)"`
+	B  string `doc:"name(x)"`
+}
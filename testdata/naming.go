@@ -0,0 +1,14 @@
+package bad
+
+// UserAccount and AccountEvent are named by the naming setting.
+type UserAccount struct {
+	UserID      string `doc:"key"`
+	FoundedYear int
+	Nickname    string `doc:"name(nick)"`
+}
+
+type AccountEvent struct {
+	EventID string `doc:"key"`
+
+	_table int `doc:"naming(camel)"`
+}
@@ -0,0 +1,9 @@
+package bad
+
+// Note compresses single fields, which
+// every backend can do.
+type Note struct {
+	Id   string   `doc:"key"`
+	Body string   `doc:"compress(gzip)"`
+	Tags []string `doc:"format(gob), compress(flate)"`
+}
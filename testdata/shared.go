@@ -0,0 +1,17 @@
+package bad
+
+// Shared and SharedToo store different columns in one table.
+type Shared struct {
+	Name  string `doc:"key"`
+	Value int64
+
+	_table int `doc:"name(shared)"`
+}
+
+type SharedToo struct {
+	Name  string `doc:"key"`
+	Value string
+	Extra string
+
+	_table int `doc:"name(shared)"`
+}
@@ -0,0 +1,9 @@
+package bad
+
+// Sized sets sqlite column types, which bbolt
+// doesn't have.
+type Sized struct {
+	Id    string `doc:"key"`
+	Notes string `doc:"type(TEXT)"`
+	Code  string `doc:"size(4)"`
+}